@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"uber_fx_init_folder_structure/config"
+	"uber_fx_init_folder_structure/pkg/cache"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/experiment"
+	"uber_fx_init_folder_structure/pkg/llm"
+	"uber_fx_init_folder_structure/pkg/memory"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/pkg/usage"
+	"uber_fx_init_folder_structure/pkg/user"
+	"uber_fx_init_folder_structure/utils"
+	"uber_fx_init_folder_structure/utils/initialize"
+
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// bootstrapAdminRun gives the admin role to --bootstrap_username and exits.
+// It refuses once anybody is an admin, later admins are assigned through /v1/admin.
+func bootstrapAdminRun() {
+	var (
+		conf        *viper.Viper
+		userService *user.Service
+		rbacService *rbac.Service
+	)
+	app := fx.New(
+		fx.Provide(
+			// postgres server
+			initialize.NewDB,
+			initialize.NewRedisWorker,
+		),
+		config.Module,
+		initialize.Module,
+		user.Module,
+		cache.Module,
+		storage.Module,
+		queue.Module,
+		rbac.Module,
+		usage.Module,
+		prompt.Module,
+		conversation.Module,
+		experiment.Module,
+		memory.Module,
+		llm.Module,
+		fx.Populate(&conf, &userService, &rbacService),
+	)
+
+	ctx := context.Background()
+	if err := app.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+	defer app.Stop(ctx)
+
+	username := conf.GetString(utils.BootstrapUsername)
+	if username == "" {
+		log.Fatal("set --bootstrap_username to a registered user")
+	}
+	u, err := userService.FetchUserByUsername(ctx, username)
+	if err != nil {
+		log.Fatalf("user %s: %v", username, err)
+	}
+	if err := rbacService.BootstrapAdmin(ctx, u.ID); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s is now an admin", username)
+}
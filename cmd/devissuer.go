@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"uber_fx_init_folder_structure/config"
+	"uber_fx_init_folder_structure/internal/devissuer"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// devIssuerRun serves a stand-in OpenID Connect issuer for trying single sign-on locally.
+// Run the server with --oidc_issuer=http://<dev_issuer_addr> and --oidc_client_id=<any>.
+func devIssuerRun() {
+	conf := config.New()
+	addr := conf.GetString(utils.DevIssuerAddr)
+	issuer, err := devissuer.New("http://"+addr, logrus.StandardLogger())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("dev issuer listening on http://%s", addr)
+	log.Fatal(http.ListenAndServe(addr, issuer.Handler()))
+}
@@ -1,5 +1,18 @@
 package main
 
+import "uber_fx_init_folder_structure/config"
+
 func main() {
-	serverRun()
+	switch config.New().GetString("mode") {
+	case "worker":
+		workerRun()
+	case "reconcile":
+		reconcileRun()
+	case "dev-issuer":
+		devIssuerRun()
+	case "bootstrap-admin":
+		bootstrapAdminRun()
+	default:
+		serverRun()
+	}
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"uber_fx_init_folder_structure/config"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/pkg/user"
+	"uber_fx_init_folder_structure/utils/initialize"
+
+	"go.uber.org/fx"
+)
+
+// reconcileRun compares the bucket with the user_images table once, prints the report as JSON and exits.
+// Pass --reconcile_fix=true to delete orphaned objects and deactivate dangling rows.
+func reconcileRun() {
+	var photoService *user.PhotoService
+	app := fx.New(
+		fx.Provide(
+			// postgres server
+			initialize.NewDB,
+			initialize.NewRedisWorker,
+		),
+		config.Module,
+		initialize.Module,
+		user.JobsModule,
+		storage.Module,
+		queue.Module,
+		rbac.Module,
+		fx.Populate(&photoService),
+	)
+
+	ctx := context.Background()
+	if err := app.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+	defer app.Stop(ctx)
+
+	report, err := photoService.Reconcile(ctx, photoService.ReconcileOptionsFromConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -4,7 +4,23 @@ import (
 	"uber_fx_init_folder_structure/config"
 	server "uber_fx_init_folder_structure/internal"
 	"uber_fx_init_folder_structure/internal/handler"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/auth"
 	"uber_fx_init_folder_structure/pkg/cache"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/experiment"
+	"uber_fx_init_folder_structure/pkg/export"
+	"uber_fx_init_folder_structure/pkg/llm"
+	"uber_fx_init_folder_structure/pkg/memory"
+	"uber_fx_init_folder_structure/pkg/notify"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/pkg/ratelimit"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/share"
+	"uber_fx_init_folder_structure/pkg/sso"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/pkg/usage"
 	"uber_fx_init_folder_structure/pkg/user"
 	"uber_fx_init_folder_structure/utils/initialize"
 
@@ -24,6 +40,22 @@ func serverRun() {
 		handler.Module,
 		user.Module,
 		cache.Module,
+		storage.Module,
+		notify.Module,
+		export.Module,
+		queue.Module,
+		rbac.Module,
+		usage.Module,
+		prompt.Module,
+		conversation.Module,
+		experiment.Module,
+		memory.Module,
+		llm.Module,
+		auth.Module,
+		apikey.Module,
+		sso.Module,
+		share.Module,
+		ratelimit.Module,
 	)
 
 	// Run app forever
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"uber_fx_init_folder_structure/config"
+	"uber_fx_init_folder_structure/pkg/export"
+	"uber_fx_init_folder_structure/pkg/notify"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/pkg/user"
+	"uber_fx_init_folder_structure/utils/initialize"
+
+	"go.uber.org/fx"
+)
+
+// workerRun starts only what is needed to consume the job queue, without the HTTP server
+func workerRun() {
+	app := fx.New(
+		fx.Provide(
+			// postgres server
+			initialize.NewDB,
+			initialize.NewRedisWorker,
+		),
+		config.Module,
+		initialize.Module,
+		user.JobsModule,
+		storage.Module,
+		notify.Module,
+		export.JobsModule,
+		queue.Module,
+		rbac.Module,
+		queue.WorkerModule,
+	)
+
+	// Run app forever
+	app.Run()
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"sync"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"go.uber.org/fx"
@@ -18,9 +20,20 @@ type argvMeta struct {
 	defaultVal string
 }
 
+var (
+	once   sync.Once
+	config *viper.Viper
+)
+
 // New returns a viper object.
 // This object is used to read environment variables or command line arguments.
-func New() (config *viper.Viper) {
+// Command line flags can only be defined once, so every call returns the same object.
+func New() *viper.Viper {
+	once.Do(load)
+	return config
+}
+
+func load() {
 	config = viper.New()
 
 	confList := map[string]argvMeta{
@@ -54,7 +67,7 @@ func New() (config *viper.Viper) {
 		},
 		"mode": {
 			defaultVal: "server",
-			desc:       "App mode eg. consumer, server, worker",
+			desc:       "App mode eg. consumer, server, worker, reconcile, dev-issuer, bootstrap-admin",
 		},
 		"log_level": {
 			defaultVal: "debug",
@@ -64,6 +77,102 @@ func New() (config *viper.Viper) {
 			defaultVal: "",
 			desc:       "open ai api key",
 		},
+		"llm_driver": {
+			defaultVal: "openai",
+			desc:       "chat model driver: openai, azure, local for an OpenAI compatible server or scripted to run offline",
+		},
+		"llm_profiles": {
+			defaultVal: "",
+			desc:       "JSON file of the model profiles used by each stage of a chat message, empty for the built-in profiles",
+		},
+		"default_locale": {
+			defaultVal: "en",
+			desc:       "language the bot replies in when the client does not send Accept-Language",
+		},
+		"llm_prompt_cache_ttl": {
+			defaultVal: "5m",
+			desc:       "how long the active system prompt is cached, activating a version clears the cache",
+		},
+		"llm_context_token_budget": {
+			defaultVal: "3000",
+			desc:       "estimated tokens the messages of a chat completion may use before the older turns of the chat are summarized, 0 never summarizes",
+		},
+		"llm_recent_turns": {
+			defaultVal: "4",
+			desc:       "latest turns of a chat sent verbatim when the older ones are summarized",
+		},
+		"llm_timeout": {
+			defaultVal: "30s",
+			desc:       "timeout of one call to the chat model",
+		},
+		"llm_max_retries": {
+			defaultVal: "2",
+			desc:       "retries of a chat model call failing with a timeout, 429 or 5xx",
+		},
+		"llm_retry_backoff": {
+			defaultVal: "500ms",
+			desc:       "wait before the first retry, doubled on every retry, a longer Retry-After is honoured",
+		},
+		"llm_max_retry_wait": {
+			defaultVal: "20s",
+			desc:       "longest wait before a retry, the call fails when the model asks for more",
+		},
+		"llm_breaker_failures": {
+			defaultVal: "5",
+			desc:       "failed chat model calls in a row that stop calling the model for llm_breaker_cooldown, 0 never stops",
+		},
+		"llm_breaker_cooldown": {
+			defaultVal: "30s",
+			desc:       "how long a failing chat model is not called",
+		},
+		"llm_fallback_driver": {
+			defaultVal: "",
+			desc:       "driver of the model answering when the chat model is unavailable, empty for the chat model's driver",
+		},
+		"llm_fallback_model": {
+			defaultVal: "",
+			desc:       "model answering when the chat model is unavailable, there is no fallback when it and llm_fallback_driver are empty",
+		},
+		"llm_fallback_base_url": {
+			defaultVal: "",
+			desc:       "API base URL of the fallback model, empty for llm_base_url when the drivers are the same",
+		},
+		"llm_fallback_api_key": {
+			defaultVal: "",
+			desc:       "API key of the fallback model, empty for open_ai_api_key",
+		},
+		"llm_cassette_mode": {
+			defaultVal: "",
+			desc:       "record to store the chat model traffic in llm_cassette_dir, replay to answer from it, empty to do neither",
+		},
+		"llm_cassette_dir": {
+			defaultVal: "cassettes",
+			desc:       "directory of the recorded chat model cassettes",
+		},
+		"llm_clock": {
+			defaultVal: "",
+			desc:       "RFC 3339 time the prompts are rendered with instead of the current time, pinned to 2024-05-01T09:30:00Z for cassettes when empty",
+		},
+		"llm_script": {
+			defaultVal: "",
+			desc:       "script file answering chat messages with llm_driver scripted",
+		},
+		"llm_model": {
+			defaultVal: "gpt-3.5-turbo",
+			desc:       "chat model name, e.g. llama3 for a local server",
+		},
+		"llm_base_url": {
+			defaultVal: "",
+			desc:       "API base URL, the resource endpoint for azure, e.g. http://localhost:11434/v1 for local",
+		},
+		"llm_azure_api_version": {
+			defaultVal: "2024-02-01",
+			desc:       "Azure OpenAI API version",
+		},
+		"llm_azure_deployments": {
+			defaultVal: "",
+			desc:       "space separated <model>=<deployment> Azure deployment names, models without one use their own name",
+		},
 		"aws_access_key": {
 			defaultVal: "",
 			desc:       "aws access key",
@@ -84,6 +193,148 @@ func New() (config *viper.Viper) {
 			defaultVal: "localhost:6379",
 			desc:       "redis server",
 		},
+		"queue_concurrency": {
+			defaultVal: "4",
+			desc:       "number of jobs a worker processes in parallel",
+		},
+		"queue_max_attempts": {
+			defaultVal: "5",
+			desc:       "attempts before a failing job is moved to the dead letter queue",
+		},
+		"queue_visibility_timeout": {
+			defaultVal: "10m",
+			desc:       "how long a job may run before it is handed to another worker",
+		},
+		"queue_backoff": {
+			defaultVal: "10s",
+			desc:       "delay before the first retry of a failed job, doubled on every attempt",
+		},
+		"queue_poll_interval": {
+			defaultVal: "1s",
+			desc:       "how often an idle worker checks for new jobs",
+		},
+		"reconcile_fix": {
+			defaultVal: "false",
+			desc:       "let storage reconciliation delete orphaned objects and deactivate dangling photo rows instead of only reporting them",
+		},
+		"reconcile_grace_period": {
+			defaultVal: "24h",
+			desc:       "objects and photo rows younger than this are never changed by storage reconciliation",
+		},
+		"reconcile_interval": {
+			defaultVal: "24h",
+			desc:       "how often the worker reconciles storage objects with photo rows, 0 to disable",
+		},
+		"jwt_secret": {
+			defaultVal: "",
+			desc:       "secret used to sign access tokens, a random one is used when empty",
+		},
+		"jwt_issuer": {
+			defaultVal: "golang_ai_chatbot",
+			desc:       "issuer of access tokens",
+		},
+		"jwt_access_ttl": {
+			defaultVal: "15m",
+			desc:       "lifetime of an access token",
+		},
+		"jwt_refresh_ttl": {
+			defaultVal: "720h",
+			desc:       "lifetime of a refresh token, it is renewed on every refresh",
+		},
+		"oidc_issuer": {
+			defaultVal: "",
+			desc:       "OpenID Connect issuer URL, single sign-on is disabled when empty",
+		},
+		"oidc_client_id": {
+			defaultVal: "",
+			desc:       "client id registered at the OpenID Connect issuer",
+		},
+		"oidc_client_secret": {
+			defaultVal: "",
+			desc:       "client secret, leave empty for a public client",
+		},
+		"oidc_redirect_url": {
+			defaultVal: "http://localhost:8765/v1/sso/callback",
+			desc:       "callback URL registered at the OpenID Connect issuer",
+		},
+		"oidc_scopes": {
+			defaultVal: "openid email profile",
+			desc:       "space separated scopes requested from the issuer",
+		},
+		"dev_issuer_addr": {
+			defaultVal: "127.0.0.1:9998",
+			desc:       "listen address of the local stand-in OpenID Connect issuer (mode dev-issuer)",
+		},
+		"bootstrap_username": {
+			defaultVal: "",
+			desc:       "registered username made the first admin by mode bootstrap-admin",
+		},
+		"public_url": {
+			defaultVal: "http://localhost:8765",
+			desc:       "URL the server is reached at, used to build share links",
+		},
+		"share_link_default_expiry": {
+			defaultVal: "24h",
+			desc:       "lifetime of a share link when none is given",
+		},
+		"share_link_max_expiry": {
+			defaultVal: "720h",
+			desc:       "longest lifetime a share link can be given",
+		},
+		"share_presign_expiry": {
+			defaultVal: "15m",
+			desc:       "lifetime of the storage URLs handed out when a share link is opened",
+		},
+		"rate_limits": {
+			defaultVal: "auth.ip=20/1m api.user=300/1m api.api_key=300/1m api.ip=600/1m " +
+				"chat.user=20/1m chat.api_key=20/1m chat.ip=60/1m " +
+				"upload.user=30/1m upload.api_key=30/1m upload.ip=60/1m share.ip=120/1m",
+			desc: "space separated <name>.<ip|user|api_key>=<requests>/<window> limits, a subject without a rule is not limited",
+		},
+		"trusted_proxies": {
+			defaultVal: "",
+			desc:       "space separated IPs or CIDRs of the proxies whose X-Forwarded-For header gives the client IP, none by default",
+		},
+		"llm_prices": {
+			defaultVal: "gpt-3.5-turbo=0.0005:0.0015",
+			desc:       "space separated <model>=<prompt>:<completion> USD per 1000 tokens, used to estimate the cost of chat messages",
+		},
+		"llm_daily_token_budget": {
+			defaultVal: "100000",
+			desc:       "tokens a user may spend on chat messages per UTC day, 0 is unlimited",
+		},
+		"llm_monthly_token_budget": {
+			defaultVal: "2000000",
+			desc:       "tokens a user may spend on chat messages per calendar month, 0 is unlimited",
+		},
+		"memory_max_facts": {
+			defaultVal: "50",
+			desc:       "facts the bot may remember about a user, 0 is unlimited",
+		},
+		"memory_prompt_facts": {
+			defaultVal: "10",
+			desc:       "facts about the user sent with each chat message, the most relevant to the message first, 0 sends every fact",
+		},
+		"thumbnail_size": {
+			defaultVal: "320",
+			desc:       "longest side in pixels of generated photo thumbnails",
+		},
+		"trash_retention_days": {
+			defaultVal: "30",
+			desc:       "days a deleted photo stays in the trash bin before it is purged",
+		},
+		"trash_purge_interval": {
+			defaultVal: "1h",
+			desc:       "how often the trash bin is checked for photos to purge eg. 30m, 1h",
+		},
+		"export_link_expiry": {
+			defaultVal: "24h",
+			desc:       "how long a photo export can be downloaded before it is deleted, at most 168h",
+		},
+		"export_cleanup_interval": {
+			defaultVal: "1h",
+			desc:       "how often expired photo exports are deleted",
+		},
 	}
 
 	for key, meta := range confList {
@@ -96,5 +347,4 @@ func New() (config *viper.Viper) {
 
 	pflag.Parse()
 	config.BindPFlags(pflag.CommandLine)
-	return
 }
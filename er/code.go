@@ -9,4 +9,17 @@ const (
 	UncaughtException Code = iota // 0
 	UserNotFound
 	Unauthorized
+	PhotoNotFound
+	ExportNotFound
+	UsernameTaken
+	APIKeyNotFound
+	GrantNotFound
+	ShareLinkNotFound
+	RoleNotFound
+	TooManyRequests
+	PromptNotFound
+	ExperimentNotFound
+	MessageNotFound
+	MemoryNotFound
+	InvalidParameter
 )
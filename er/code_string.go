@@ -9,11 +9,26 @@ func _() {
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
 	_ = x[UncaughtException-0]
+	_ = x[UserNotFound-1]
+	_ = x[Unauthorized-2]
+	_ = x[PhotoNotFound-3]
+	_ = x[ExportNotFound-4]
+	_ = x[UsernameTaken-5]
+	_ = x[APIKeyNotFound-6]
+	_ = x[GrantNotFound-7]
+	_ = x[ShareLinkNotFound-8]
+	_ = x[RoleNotFound-9]
+	_ = x[TooManyRequests-10]
+	_ = x[PromptNotFound-11]
+	_ = x[ExperimentNotFound-12]
+	_ = x[MessageNotFound-13]
+	_ = x[MemoryNotFound-14]
+	_ = x[InvalidParameter-15]
 }
 
-const _Code_name = "UncaughtException"
+const _Code_name = "UncaughtExceptionUserNotFoundUnauthorizedPhotoNotFoundExportNotFoundUsernameTakenAPIKeyNotFoundGrantNotFoundShareLinkNotFoundRoleNotFoundTooManyRequestsPromptNotFoundExperimentNotFoundMessageNotFoundMemoryNotFoundInvalidParameter"
 
-var _Code_index = [...]uint16{0}
+var _Code_index = [...]uint16{0, 17, 29, 41, 54, 68, 81, 95, 108, 125, 137, 152, 166, 184, 199, 213, 229}
 
 func (i Code) String() string {
 	if i < 0 || i >= Code(len(_Code_index)-1) {
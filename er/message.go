@@ -1,13 +1,39 @@
 package er
 
 var messages = map[string]string{
-	"1": "Oops! Something went wrong. Please try later",
-	"2": "User not found",
-	"3": "unauthorized",
+	"1":  "Oops! Something went wrong. Please try later",
+	"2":  "User not found",
+	"3":  "unauthorized",
+	"4":  "Photo not found",
+	"5":  "Export not found",
+	"6":  "Username is already taken",
+	"7":  "API key not found",
+	"8":  "Grant not found",
+	"9":  "Share link not found or expired",
+	"10": "Role not found",
+	"11": "Too many requests, please slow down",
+	"12": "Prompt not found",
+	"13": "Experiment not found",
+	"14": "Message not found",
+	"15": "Memory not found",
+	"16": "Invalid request parameter",
 }
 
 var codes = map[Code]string{
-	UncaughtException: "1",
-	UserNotFound:      "2",
-	Unauthorized:      "3",
+	UncaughtException:  "1",
+	UserNotFound:       "2",
+	Unauthorized:       "3",
+	PhotoNotFound:      "4",
+	ExportNotFound:     "5",
+	UsernameTaken:      "6",
+	APIKeyNotFound:     "7",
+	GrantNotFound:      "8",
+	ShareLinkNotFound:  "9",
+	RoleNotFound:       "10",
+	TooManyRequests:    "11",
+	PromptNotFound:     "12",
+	ExperimentNotFound: "13",
+	MessageNotFound:    "14",
+	MemoryNotFound:     "15",
+	InvalidParameter:   "16",
 }
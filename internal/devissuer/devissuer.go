@@ -0,0 +1,202 @@
+// Package devissuer is a minimal OpenID Connect issuer to try single sign-on locally.
+// It signs in whoever asks, as the login_hint email, so it must never face the internet.
+package devissuer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	keyID   = "dev"
+	codeTTL = time.Minute
+	// defaultEmail is used when the client sends no login_hint
+	defaultEmail = "dev@example.com"
+)
+
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	email       string
+	expiresAt   time.Time
+}
+
+type Issuer struct {
+	url    string
+	log    *logrus.Logger
+	key    *rsa.PrivateKey
+	signer jose.Signer
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// New returns an issuer whose URL is issuerURL, it is served by Handler
+func New(issuerURL string, log *logrus.Logger) (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{
+		url:    strings.TrimSuffix(issuerURL, "/"),
+		log:    log,
+		key:    key,
+		signer: signer,
+		grants: map[string]grant{},
+	}, nil
+}
+
+// Handler serves discovery, the keys, and the authorize and token endpoints
+func (i *Issuer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/keys", i.keys)
+	mux.HandleFunc("/authorize", i.authorize)
+	mux.HandleFunc("/token", i.token)
+	return mux
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                i.url,
+		"authorization_endpoint":                i.url + "/authorize",
+		"token_endpoint":                        i.url + "/token",
+		"jwks_uri":                              i.url + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (i *Issuer) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &i.key.PublicKey,
+		KeyID:     keyID,
+		Algorithm: string(jose.RS256),
+		Use:       "sig",
+	}}})
+}
+
+// authorize approves every request and redirects back with a code
+func (i *Issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "only the code flow with an S256 code_challenge is supported", http.StatusBadRequest)
+		return
+	}
+	email := q.Get("login_hint")
+	if email == "" {
+		email = defaultEmail
+	}
+	code := randomString()
+	i.mu.Lock()
+	i.grants[code] = grant{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		email:       email,
+		expiresAt:   time.Now().Add(codeTTL),
+	}
+	i.mu.Unlock()
+	i.log.WithField("email", email).Info("dev issuer: signed in")
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	code := r.PostForm.Get("code")
+	i.mu.Lock()
+	g, ok := i.grants[code]
+	delete(i.grants, code)
+	i.mu.Unlock()
+
+	clientID := r.PostForm.Get("client_id")
+	if id, _, hasBasic := r.BasicAuth(); hasBasic {
+		clientID = id
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if !ok || time.Now().After(g.expiresAt) ||
+		g.clientID != clientID ||
+		g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		subtle.ConstantTimeCompare([]byte(challenge), []byte(g.challenge)) != 1 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	local, _, _ := strings.Cut(g.email, "@")
+	idToken, err := jwt.Signed(i.signer).Claims(jwt.Claims{
+		Issuer:   i.url,
+		Subject:  "dev|" + g.email,
+		Audience: jwt.Audience{g.clientID},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}).Claims(map[string]interface{}{
+		"nonce":              g.nonce,
+		"email":              g.email,
+		"email_verified":     true,
+		"preferred_username": local,
+	}).CompactSerialize()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
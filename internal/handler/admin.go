@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/user"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type AdminHandler struct {
+	log          *logrus.Logger
+	userService  *user.Service
+	rbacService  *rbac.Service
+	queueService *queue.Service
+}
+
+func newAdminHandler(
+	log *logrus.Logger,
+	userService *user.Service,
+	rbacService *rbac.Service,
+	queueService *queue.Service,
+) *AdminHandler {
+	return &AdminHandler{
+		log,
+		userService,
+		rbacService,
+		queueService,
+	}
+}
+
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	roles, err := h.rbacService.Roles(dCtx)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = roles
+	res.Meta = gin.H{"permissions": rbac.PermissionNames()}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) SaveRole(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.RoleReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	role, err := h.rbacService.SaveRole(dCtx, mw.CurrentUser(c).ID, req.Name, req.Description, req.Permissions)
+	if errors.Is(err, rbac.ErrInvalidPermission) || err == rbac.ErrAdminRoleReadonly {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, rbac.ErrEscalation) {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "role saved"
+	res.Success = true
+	res.Data = role
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) ListUserRoles(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	userDetails, err := h.userService.FetchUserByUsername(dCtx, c.Param("username"))
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	roles, err := h.rbacService.UserRoles(dCtx, userDetails.ID)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = roles
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) AssignRole(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	userDetails, err := h.userService.FetchUserByUsername(dCtx, c.Param("username"))
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	err = h.rbacService.Assign(dCtx, mw.CurrentUser(c).ID, userDetails.ID, c.Param("role"))
+	if err == rbac.ErrRoleNotFound {
+		err = er.New(err, er.RoleNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, rbac.ErrEscalation) {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "role assigned"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) UnassignRole(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	userDetails, err := h.userService.FetchUserByUsername(dCtx, c.Param("username"))
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	err = h.rbacService.Unassign(dCtx, mw.CurrentUser(c).ID, userDetails.ID, c.Param("role"))
+	if err == rbac.ErrRoleNotFound || err == pg.ErrNoRows {
+		err = er.New(err, er.RoleNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, rbac.ErrEscalation) {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "role unassigned"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) QueueStats(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	limit, _ := strconv.Atoi(c.DefaultQuery("dead_limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+	stats, err := h.queueService.Stats(dCtx)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	dead, err := h.queueService.DeadLetters(dCtx, limit)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = gin.H{"stats": stats, "dead_letters": dead}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) Reconcile(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := h.queueService.Enqueue(dCtx, user.JobReconcile, nil)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "storage reconciliation queued"
+	res.Success = true
+	res.Data = gin.H{"job_id": id}
+	c.JSON(http.StatusAccepted, res)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type APIKeyHandler struct {
+	log           *logrus.Logger
+	apikeyService *apikey.Service
+}
+
+func newAPIKeyHandler(
+	log *logrus.Logger,
+	apikeyService *apikey.Service,
+) *APIKeyHandler {
+	return &APIKeyHandler{
+		log,
+		apikeyService,
+	}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.APIKeyReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	raw, key, err := h.apikeyService.Create(dCtx, mw.CurrentUser(c).ID, req.Name, req.Scopes)
+	if errors.Is(err, apikey.ErrInvalidScope) || err == apikey.ErrNoScopes {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "store the key now, it is not shown again"
+	res.Success = true
+	res.Data = gin.H{"key": raw, "api_key": key}
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	keys, err := h.apikeyService.List(dCtx, mw.CurrentUser(c).ID)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = keys
+	res.Meta = gin.H{"scopes": apikey.Scopes}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	err = h.apikeyService.Revoke(dCtx, mw.CurrentUser(c).ID, id)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.APIKeyNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "api key revoked"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/auth"
+	"uber_fx_init_folder_structure/pkg/sso"
+	"uber_fx_init_folder_structure/pkg/user"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type AuthHandler struct {
+	log         *logrus.Logger
+	userService *user.Service
+	authService *auth.Service
+	ssoService  *sso.Service
+}
+
+func newAuthHandler(
+	log *logrus.Logger,
+	userService *user.Service,
+	authService *auth.Service,
+	ssoService *sso.Service,
+) *AuthHandler {
+	return &AuthHandler{
+		log,
+		userService,
+		authService,
+		ssoService,
+	}
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.LoginReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	userDetails, err := h.userService.Authenticate(dCtx, req.Username, req.Password)
+	if err == user.ErrInvalidCredentials {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore()
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	tokens, err := h.authService.IssueTokens(dCtx, userDetails)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "Login Sucessfully Done"
+	res.Success = true
+	res.Data = gin.H{"user": userDetails, "tokens": tokens}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.RefreshTokenReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	tokens, err := h.authService.Refresh(dCtx, req.RefreshToken, h.userService.FetchUserByID)
+	if err == auth.ErrInvalidToken || err == auth.ErrRefreshReused {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore()
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = tokens
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.RefreshTokenReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	err = h.authService.Revoke(dCtx, req.RefreshToken)
+	if err == auth.ErrInvalidToken {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore()
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "logged out"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// SSOLogin redirects to the OpenID Connect issuer, `?redirect=false` returns the URL instead
+func (h *AuthHandler) SSOLogin(c *gin.Context) {
+	h.startSSO(c, 0)
+}
+
+// SSOLink redirects the signed in user to the issuer to link their account to the identity they sign in with,
+// it takes the same query as SSOLogin
+func (h *AuthHandler) SSOLink(c *gin.Context) {
+	h.startSSO(c, mw.CurrentUser(c).ID)
+}
+
+// startSSO starts a sign in at the issuer, or the linking of the identity to the user with linkUserID.
+// The state is also set in an HttpOnly cookie so that the callback only completes in the browser that started it.
+func (h *AuthHandler) startSSO(c *gin.Context, linkUserID int) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	url, state, err := h.ssoService.AuthCodeURL(dCtx, c.Query("login_hint"), linkUserID)
+	if err == sso.ErrDisabled {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusNotFound).Ignore()
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadGateway)
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sso.StateCookie, state, int(sso.StateTTL.Seconds()), ssoCookiePath, "", h.ssoService.SecureCookie(), true)
+	if c.Query("redirect") == "false" {
+		res.Success = true
+		res.Data = gin.H{"url": url}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// ssoCookiePath limits the state cookie to the single sign-on routes
+const ssoCookiePath = "/v1/sso/"
+
+// SSOCallback is where the issuer sends the user back, it answers like Login
+func (h *AuthHandler) SSOCallback(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if msg := c.Query("error"); msg != "" {
+		err = er.New(errors.New(msg+": "+c.Query("error_description")), er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore()
+		return
+	}
+	state, _ := c.Cookie(sso.StateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sso.StateCookie, "", -1, ssoCookiePath, "", h.ssoService.SecureCookie(), true)
+	if state == "" || state != c.Query("state") {
+		// the sign in was started in another browser, e.g. a link sent to the user
+		err = er.New(sso.ErrInvalidState, er.Unauthorized).SetStatus(http.StatusBadRequest).Ignore()
+		return
+	}
+	userDetails, err := h.ssoService.Exchange(dCtx, state, c.Query("code"))
+	if err == sso.ErrDisabled {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusNotFound).Ignore()
+		return
+	}
+	if err == sso.ErrInvalidState {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusBadRequest).Ignore()
+		return
+	}
+	if err == user.ErrSSOLinked {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusConflict).Ignore()
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized)
+		return
+	}
+	tokens, err := h.authService.IssueTokens(dCtx, userDetails)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "Login Sucessfully Done"
+	res.Success = true
+	res.Data = gin.H{"user": userDetails, "tokens": tokens}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/experiment"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ExperimentHandler struct {
+	log               *logrus.Logger
+	experimentService *experiment.Service
+}
+
+func newExperimentHandler(
+	log *logrus.Logger,
+	experimentService *experiment.Service,
+) *ExperimentHandler {
+	return &ExperimentHandler{
+		log,
+		experimentService,
+	}
+}
+
+func (h *ExperimentHandler) ListExperiments(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	experiments, err := h.experimentService.Experiments(dCtx)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = experiments
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ExperimentHandler) StartExperiment(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.ExperimentReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	variants := []experiment.Variant{}
+	for _, v := range req.Variants {
+		variants = append(variants, experiment.Variant{
+			Name:          v.Name,
+			PromptVersion: v.PromptVersion,
+			Profile:       v.Profile,
+			Weight:        v.Weight,
+		})
+	}
+	started, err := h.experimentService.Start(dCtx, req.Name, req.Description, variants, mw.CurrentUser(c).ID)
+	if errors.Is(err, experiment.ErrInvalidVariants) {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err == experiment.ErrExperimentRunning || err == experiment.ErrExperimentExists {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "experiment started"
+	res.Success = true
+	res.Data = started
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *ExperimentHandler) StopExperiment(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.ExperimentNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	err = h.experimentService.Stop(dCtx, id)
+	if err == experiment.ErrExperimentNotFound {
+		err = er.New(err, er.ExperimentNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "experiment stopped"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ExperimentHandler) Report(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.ExperimentNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	report, err := h.experimentService.Report(dCtx, id)
+	if err == experiment.ErrExperimentNotFound {
+		err = er.New(err, er.ExperimentNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = report
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/export"
+	"uber_fx_init_folder_structure/pkg/user"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type ExportHandler struct {
+	log           *logrus.Logger
+	exportService *export.Service
+}
+
+func newExportHandler(
+	log *logrus.Logger,
+	exportService *export.Service,
+) *ExportHandler {
+	return &ExportHandler{
+		log,
+		exportService,
+	}
+}
+
+func (h *ExportHandler) CreateExport(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.ExportReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	from, to, err := export.ParseDateRange(req.From, req.To)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	userDetails := mw.CurrentUser(c)
+	job, err := h.exportService.Start(dCtx, userDetails, export.Request{Album: req.Album, From: from, To: to})
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "export started, progress is sent to the chat"
+	res.Success = true
+	res.Data = job
+	c.JSON(http.StatusAccepted, res)
+}
+
+func (h *ExportHandler) FetchExport(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	userDetails := mw.CurrentUser(c)
+	job, err := h.exportService.FetchJob(dCtx, userDetails, id)
+	if err == user.ErrForbidden {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.ExportNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err == export.ErrExpired {
+		err = er.New(err, er.ExportNotFound).SetStatus(http.StatusGone).Ignore()
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = job
+	c.JSON(http.StatusOK, res)
+}
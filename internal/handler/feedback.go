@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// feedbackExportBatch is how many feedbacks are read from the database at a time while exporting
+const feedbackExportBatch = 500
+
+type FeedbackHandler struct {
+	log                 *logrus.Logger
+	conversationService *conversation.Service
+}
+
+func newFeedbackHandler(
+	log *logrus.Logger,
+	conversationService *conversation.Service,
+) *FeedbackHandler {
+	return &FeedbackHandler{
+		log,
+		conversationService,
+	}
+}
+
+func (h *FeedbackHandler) SaveFeedback(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.FeedbackReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.MessageNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	feedback, err := h.conversationService.SaveFeedback(dCtx, mw.CurrentUser(c).ID, id, req.Rating, req.Comment, req.Reasons)
+	if err == conversation.ErrMessageNotFound {
+		err = er.New(err, er.MessageNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, conversation.ErrInvalidFeedback) {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "thanks for the feedback"
+	res.Success = true
+	res.Data = feedback
+	c.JSON(http.StatusOK, res)
+}
+
+// ExportFeedback streams the feedback given in the period as JSON lines, one feedback with
+// the answer it rates and the question it answered per line
+func (h *FeedbackHandler) ExportFeedback(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.UsageReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBindQuery(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	from, to, err := usagePeriod(req)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="feedback-%s-%s.jsonl"`,
+		from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102")))
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	exportErr := h.conversationService.ExportFeedback(dCtx, from, to, feedbackExportBatch, func(exports []conversation.FeedbackExport) error {
+		for _, export := range exports {
+			if err := enc.Encode(export); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if exportErr != nil {
+		// the status is sent, the export ends early
+		h.log.Error("feedback export: " + exportErr.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/user"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+)
+
+// SharePhotos grants another user access to the photos of the current user
+func (h *UserHandler) SharePhotos(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.GrantReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	grant, err := h.userService.Share(dCtx, mw.CurrentUser(c), req.Username, req.Album, req.Permission)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err == user.ErrSelfGrant || err == user.ErrInvalidPermission {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "photos shared with " + grant.GranteeUsername
+	res.Success = true
+	res.Data = grant
+	c.JSON(http.StatusOK, res)
+}
+
+// ListGrants returns the grants the current user gave and received
+func (h *UserHandler) ListGrants(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	given, received, err := h.userService.Grants(dCtx, mw.CurrentUser(c).ID)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = gin.H{"given": given, "received": received}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) RevokeGrant(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	err = h.userService.RevokeGrant(dCtx, mw.CurrentUser(c).ID, id)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.GrantNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "access revoked"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
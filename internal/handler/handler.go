@@ -6,5 +6,15 @@ import "go.uber.org/fx"
 var Module = fx.Options(
 	fx.Provide(
 		newUserHandler,
+		newExportHandler,
+		newAuthHandler,
+		newAPIKeyHandler,
+		newShareHandler,
+		newAdminHandler,
+		newUsageHandler,
+		newPromptHandler,
+		newExperimentHandler,
+		newFeedbackHandler,
+		newMemoryHandler,
 	),
 )
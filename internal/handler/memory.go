@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/memory"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type MemoryHandler struct {
+	log           *logrus.Logger
+	memoryService *memory.Service
+}
+
+func newMemoryHandler(
+	log *logrus.Logger,
+	memoryService *memory.Service,
+) *MemoryHandler {
+	return &MemoryHandler{
+		log,
+		memoryService,
+	}
+}
+
+func (h *MemoryHandler) ListMemories(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	memories, err := h.memoryService.Memories(dCtx, mw.CurrentUser(c).ID)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = memories
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *MemoryHandler) DeleteMemory(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.MemoryNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	err = h.memoryService.Forget(dCtx, mw.CurrentUser(c).ID, id)
+	if err == memory.ErrMemoryNotFound {
+		err = er.New(err, er.MemoryNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "memory deleted"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *MemoryHandler) DeleteMemories(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	deleted, err := h.memoryService.ForgetAll(dCtx, mw.CurrentUser(c).ID)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "memories deleted"
+	res.Success = true
+	res.Data = gin.H{"deleted": deleted}
+	c.JSON(http.StatusOK, res)
+}
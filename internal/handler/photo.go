@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/user"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+)
+
+func (h *UserHandler) TrashPhoto(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	photoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	userDetails := mw.CurrentUser(c)
+	err = h.userService.TrashPhoto(dCtx, userDetails, photoID)
+	if err == user.ErrForbidden {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.PhotoNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "photo moved to trash"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) ListTrash(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	userDetails := mw.CurrentUser(c)
+	userImages, err := h.userService.RetrieveTrash(dCtx, userDetails.ID)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = userImages
+	res.Meta = gin.H{"retention_days": int(h.userService.TrashRetention().Hours() / 24)}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) RestorePhoto(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	photoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	userDetails := mw.CurrentUser(c)
+	err = h.userService.RestorePhoto(dCtx, userDetails, photoID)
+	if err == user.ErrForbidden {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.PhotoNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "photo restored"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) ListPhotos(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.PhotoListReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBindQuery(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	userDetails, err := h.userService.FetchUserByUsername(dCtx, c.Param("username"))
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.UserNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	err = h.userService.Authorize(dCtx, mw.CurrentUser(c), userDetails.ID, req.Album, user.PermissionRead)
+	if err == user.ErrForbidden {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	filter := user.PhotoFilter{
+		From:        req.From,
+		Tag:         req.Tag,
+		Album:       req.Album,
+		ContentType: req.ContentType,
+		Sort:        req.Sort,
+		Cursor:      req.Cursor,
+		Limit:       req.Limit,
+	}
+	if !req.To.IsZero() {
+		// `to` is an inclusive date
+		filter.To = req.To.AddDate(0, 0, 1)
+	}
+	page, err := h.userService.ListPhotos(dCtx, userDetails.ID, filter)
+	if err == user.ErrInvalidCursor || err == user.ErrCursorMismatch || err == user.ErrInvalidSort {
+		err = er.New(err, er.InvalidParameter).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = page.Photos
+	res.Meta = model.PageMeta{
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+		Limit:      page.Limit,
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/pkg/user"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// previewSessionID is the chat session the tokens of prompt previews are recorded for
+const previewSessionID = "prompt-preview"
+
+type PromptHandler struct {
+	log           *logrus.Logger
+	userService   *user.Service
+	promptService *prompt.Service
+}
+
+func newPromptHandler(
+	log *logrus.Logger,
+	userService *user.Service,
+	promptService *prompt.Service,
+) *PromptHandler {
+	return &PromptHandler{
+		log,
+		userService,
+		promptService,
+	}
+}
+
+func (h *PromptHandler) ListVersions(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	prompts, err := h.promptService.Versions(dCtx, c.Param("name"))
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = prompts
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *PromptHandler) CreateVersion(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.PromptReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	created, err := h.promptService.Create(dCtx, c.Param("name"), req.Messages, req.Note, mw.CurrentUser(c).ID)
+	if err == prompt.ErrEmptyPrompt || errors.Is(err, prompt.ErrInvalidTemplate) {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "prompt version created, activate it to use it"
+	res.Success = true
+	res.Data = created
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *PromptHandler) Preview(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.PromptPreviewReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	messages := req.Messages
+	if len(messages) == 0 {
+		var stored *prompt.Prompt
+		if req.Version > 0 {
+			stored, err = h.promptService.Version(dCtx, c.Param("name"), req.Version)
+		} else {
+			stored, err = h.promptService.Active(dCtx, c.Param("name"))
+		}
+		if err == nil {
+			messages = stored.Messages
+		}
+	}
+	if errors.Is(err, prompt.ErrPromptNotFound) {
+		err = er.New(err, er.PromptNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	reply, dialogue, err := h.userService.PreviewMessage(dCtx, mw.CurrentUser(c), mw.ChatSession(c, previewSessionID), messages, req.Message)
+	if errors.Is(err, prompt.ErrInvalidTemplate) {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = gin.H{"reply": reply, "dialogue": dialogue}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *PromptHandler) Activate(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.ActivatePromptReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	activated, err := h.promptService.Activate(dCtx, c.Param("name"), req.Version, mw.CurrentUser(c).ID)
+	if err == prompt.ErrPromptNotFound {
+		err = er.New(err, er.PromptNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "prompt version activated"
+	res.Success = true
+	res.Data = activated
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *PromptHandler) Rollback(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	activated, err := h.promptService.Rollback(dCtx, c.Param("name"), mw.CurrentUser(c).ID)
+	if err == prompt.ErrNoPrevious {
+		err = er.New(err, er.PromptNotFound).SetStatus(http.StatusConflict)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "prompt rolled back"
+	res.Success = true
+	res.Data = activated
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/share"
+	"uber_fx_init_folder_structure/pkg/user"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// sharePage renders a password form or the photos of a shared album
+var sharePage = template.Must(template.New("share").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>Shared photos</title></head>
+<body>
+{{if .PasswordRequired}}
+<form method="post">
+  {{if .WrongPassword}}<p>Wrong password, please try again.</p>{{end}}
+  <label>Password <input type="password" name="password" autofocus></label>
+  <button type="submit">Open</button>
+</form>
+{{else}}
+{{if .Content.Album}}<h1>{{.Content.Album}}</h1>{{end}}
+{{range .Content.Items}}<a href="{{.Url}}"><img src="{{.ThumbnailUrl}}" alt="photo {{.ID}}" style="max-width:320px;margin:4px"></a>
+{{end}}
+{{end}}
+</body>
+</html>`))
+
+type ShareHandler struct {
+	log          *logrus.Logger
+	shareService *share.Service
+}
+
+func newShareHandler(
+	log *logrus.Logger,
+	shareService *share.Service,
+) *ShareHandler {
+	return &ShareHandler{
+		log,
+		shareService,
+	}
+}
+
+func (h *ShareHandler) CreateShareLink(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.ShareLinkReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	link, err := h.shareService.Create(dCtx, mw.CurrentUser(c), share.Request{
+		PhotoID:   req.PhotoID,
+		Album:     req.Album,
+		Password:  req.Password,
+		ExpiresIn: time.Duration(req.Hours) * time.Hour,
+		MaxViews:  req.MaxViews,
+	})
+	if err == share.ErrInvalidTarget || err == share.ErrExpiryTooLong || err == user.ErrWeakPassword {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.PhotoNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err == user.ErrForbidden {
+		err = er.New(err, er.Unauthorized).SetStatus(http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "store the url now, it is not shown again"
+	res.Success = true
+	res.Data = link
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *ShareHandler) ListShareLinks(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	links, err := h.shareService.List(dCtx, mw.CurrentUser(c).ID)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = links
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ShareHandler) RevokeShareLink(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	err = h.shareService.Revoke(dCtx, mw.CurrentUser(c).ID, id)
+	if err == pg.ErrNoRows {
+		err = er.New(err, er.ShareLinkNotFound).SetStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Message = "share link revoked"
+	res.Success = true
+	c.JSON(http.StatusOK, res)
+}
+
+// OpenShareLink is the public page of a share link. A single photo redirects to its
+// presigned URL, an album is rendered as a page. The password is posted by the page's form.
+func (h *ShareHandler) OpenShareLink(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	content, err := h.shareService.Open(dCtx, c.Param("token"), c.PostForm("password"))
+	if err == share.ErrPasswordRequired || err == share.ErrWrongPassword {
+		c.Status(http.StatusUnauthorized)
+		sharePage.Execute(c.Writer, gin.H{"PasswordRequired": true, "WrongPassword": err == share.ErrWrongPassword})
+		err = nil
+		return
+	}
+	if err == share.ErrNotFound {
+		err = er.New(err, er.ShareLinkNotFound).SetStatus(http.StatusNotFound).Ignore()
+		return
+	}
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	if content.Album == "" && len(content.Items) == 1 {
+		c.Redirect(http.StatusFound, content.Items[0].Url)
+		return
+	}
+	c.Status(http.StatusOK)
+	sharePage.Execute(c.Writer, gin.H{"Content": content})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/usage"
+	model "uber_fx_init_folder_structure/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var errUsagePeriod = errors.New("from must be before to")
+
+type UsageHandler struct {
+	log          *logrus.Logger
+	usageService *usage.Service
+}
+
+func newUsageHandler(
+	log *logrus.Logger,
+	usageService *usage.Service,
+) *UsageHandler {
+	return &UsageHandler{
+		log,
+		usageService,
+	}
+}
+
+// Report returns the chat usage of the current user
+func (h *UsageHandler) Report(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.UsageReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBindQuery(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	from, to, err := usagePeriod(req)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	report, err := h.usageService.Report(dCtx, mw.CurrentUser(c).ID, from, to)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = report
+	c.JSON(http.StatusOK, res)
+}
+
+// UsersReport returns the chat usage of every user
+func (h *UsageHandler) UsersReport(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = model.UsageReq{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+	if err = c.ShouldBindQuery(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	from, to, err := usagePeriod(req)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
+		return
+	}
+	report, err := h.usageService.UsersReport(dCtx, from, to)
+	if err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	res.Success = true
+	res.Data = report
+	c.JSON(http.StatusOK, res)
+}
+
+// usagePeriod defaults to the current month, to is inclusive
+func usagePeriod(req model.UsageReq) (from, to time.Time, err error) {
+	now := time.Now().UTC()
+	from, to = req.From, now
+	if from.IsZero() {
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	if !req.To.IsZero() {
+		to = req.To.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		return from, to, errUsagePeriod
+	}
+	return from, to, nil
+}
@@ -2,13 +2,20 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/internal/mw"
 	model "uber_fx_init_folder_structure/utils/models"
 
 	"net/http"
-	"uber_fx_init_folder_structure/pkg/cache"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/notify"
+	"uber_fx_init_folder_structure/pkg/ratelimit"
 	"uber_fx_init_folder_structure/pkg/user"
 
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -19,26 +26,33 @@ import (
 )
 
 type UserHandler struct {
-	log          *logrus.Logger
-	userService  *user.Service
-	cacheService *cache.Service
+	log                 *logrus.Logger
+	userService         *user.Service
+	notifyService       *notify.Service
+	rateLimiter         *ratelimit.Service
+	conversationService *conversation.Service
 }
 
-var messageChan = make(chan string)
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// selected only when the client sends its access token as a subprotocol
+	Subprotocols: []string{mw.BearerSubprotocol},
 }
 
 func newUserHandler(
 	log *logrus.Logger,
 	userService *user.Service,
-	cacheService *cache.Service,
+	notifyService *notify.Service,
+	rateLimiter *ratelimit.Service,
+	conversationService *conversation.Service,
 ) *UserHandler {
 	return &UserHandler{
 		log,
 		userService,
-		cacheService,
+		notifyService,
+		rateLimiter,
+		conversationService,
 	}
 }
 
@@ -46,7 +60,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	var (
 		err  error
 		res  = model.GenericRes{}
-		req  = &user.User{}
+		req  = model.CreateUserReq{}
 		dCtx = context.Background()
 	)
 	defer func() {
@@ -60,16 +74,28 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
 		return
 	}
-	err = h.userService.UpsertUserRegistration(dCtx, req)
+	newUser := &user.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Mobile:    req.Mobile,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+	}
+	err = h.userService.Register(dCtx, newUser, req.Password)
+	if err == user.ErrUsernameTaken {
+		err = er.New(err, er.UsernameTaken).SetStatus(http.StatusConflict)
+		return
+	}
 	if err != nil {
 		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
 		return
 	}
 	res.Message = "Registration Sucessfully Done"
 	res.Success = true
-	res.Data = req
+	res.Data = newUser
 	c.JSON(http.StatusOK, res)
 }
+
 func (h *UserHandler) ChatWithBot(c *gin.Context) {
 	var (
 		err  error
@@ -89,6 +115,10 @@ func (h *UserHandler) ChatWithBot(c *gin.Context) {
 		return
 	}
 
+	userDetails := mw.CurrentUser(c)
+	subjects := mw.RateLimitSubjects(c)
+	// usage is accounted per connection
+	session := mw.ChatSession(c, uuid.NewString())
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("Error upgrading to WebSocket: %v", err)
@@ -96,40 +126,100 @@ func (h *UserHandler) ChatWithBot(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	for {
-		go func() {
-			for msg := range messageChan {
-				if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
-					log.Printf("Error writing message to WebSocket: %v", err)
-					break
-				}
+	// clients opening the chat with ?protocol=json exchange WSFrame, the others plain text
+	jsonProtocol := c.Query("protocol") == "json"
+	// gorilla/websocket supports only one concurrent writer
+	var writeMu sync.Mutex
+	send := func(frame model.WSFrame) error {
+		msg := []byte(frame.Text)
+		if jsonProtocol {
+			var err error
+			if msg, err = json.Marshal(frame); err != nil {
+				return err
+			}
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(websocket.TextMessage, msg)
+	}
+
+	notifications, unsubscribe := h.notifyService.Subscribe()
+	defer unsubscribe()
+	go func() {
+		for n := range notifications {
+			if n.Username != "" && n.Username != userDetails.Username {
+				continue
 			}
-		}()
+			if err := send(model.WSFrame{Type: "notification", Text: n.Text}); err != nil {
+				log.Printf("Error writing message to WebSocket: %v", err)
+				return
+			}
+		}
+	}()
+
+	for {
 		// Read message from WebSocket client
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
 			log.Printf("Error reading message from WebSocket: %v", err)
 			break
 		}
-		// Process the message using OpenAI API
-		response, err := h.userService.ProcessMessage(dCtx, string(msg))
-		if err != nil {
-			log.Printf("Error processing message: %v", err)
-			continue
+		frame := model.WSFrame{Type: "message", Text: string(msg)}
+		if jsonProtocol {
+			if err := json.Unmarshal(msg, &frame); err != nil {
+				frame = model.WSFrame{Type: "invalid"}
+			}
 		}
 
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(response)); err != nil {
+		var out model.WSFrame
+		switch frame.Type {
+		case "message":
+			out = h.answer(dCtx, userDetails, session, subjects, frame.Text)
+		case "feedback":
+			_, err := h.conversationService.SaveFeedback(dCtx, userDetails.ID, frame.MessageID, frame.Rating, frame.Comment, frame.Reasons)
+			switch {
+			case err == nil:
+				out = model.WSFrame{Type: "feedback", MessageID: frame.MessageID}
+			case err == conversation.ErrMessageNotFound || errors.Is(err, conversation.ErrInvalidFeedback):
+				out = model.WSFrame{Type: "error", MessageID: frame.MessageID, Text: err.Error()}
+			default:
+				log.Printf("Error saving feedback: %v", err)
+				out = model.WSFrame{Type: "error", MessageID: frame.MessageID, Text: "feedback could not be saved, please try again"}
+			}
+		default:
+			out = model.WSFrame{Type: "error", Text: "frames must be JSON with the type message or feedback"}
+		}
+		if out.Type == "" {
+			continue
+		}
+		if err := send(out); err != nil {
 			log.Printf("Error writing message to WebSocket: %v", err)
 			break
 		}
 	}
 }
 
-func (h *UserHandler) UserUploadPhoto(c *gin.Context) {
+// answer answers a chat message of the websocket, it returns no frame when the message could not be answered
+func (h *UserHandler) answer(ctx context.Context, principal *user.User, session user.Session, subjects []ratelimit.Subject, text string) model.WSFrame {
+	if limit := h.rateLimiter.Allow(ctx, "chat", subjects...); !limit.Allowed {
+		msg := fmt.Sprintf("You are sending messages too fast, please try again in %s seconds.", mw.RetryAfterSeconds(limit))
+		return model.WSFrame{Type: "error", Text: msg}
+	}
+	// Process the message using OpenAI API
+	reply, err := h.userService.ProcessMessage(ctx, principal, session, text)
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+		return model.WSFrame{}
+	}
+	return model.WSFrame{Type: "reply", ID: reply.ID, Text: reply.Content}
+}
+
+// Chat answers a single message, for scripts that do not keep a websocket open
+func (h *UserHandler) Chat(c *gin.Context) {
 	var (
 		err  error
 		res  = model.GenericRes{}
-		req  = user.UserImages{}
+		req  = model.ChatReq{}
 		dCtx = context.Background()
 	)
 	defer func() {
@@ -139,14 +229,40 @@ func (h *UserHandler) UserUploadPhoto(c *gin.Context) {
 			return
 		}
 	}()
-
-	username := ""
-	err = h.cacheService.Get("username", &username)
+	if err = c.ShouldBind(&req); err != nil {
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusUnprocessableEntity)
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = uuid.NewString()
+	}
+	reply, err := h.userService.ProcessMessage(dCtx, mw.CurrentUser(c), mw.ChatSession(c, req.SessionID), req.Message)
 	if err != nil {
-		go h.SendMessageToSocket("Please enter username in the chatbox to proceed!!")
-		err = er.New(err, er.UserNotFound).SetStatus(http.StatusBadRequest)
+		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadGateway)
 		return
 	}
+	res.Success = true
+	res.Data = reply.Content
+	res.Meta = gin.H{"session_id": req.SessionID, "message_id": reply.ID}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *UserHandler) UserUploadPhoto(c *gin.Context) {
+	var (
+		err  error
+		res  = model.GenericRes{}
+		req  = user.UserImages{}
+		dCtx = context.Background()
+	)
+	defer func() {
+		if err != nil {
+			c.Error(err)
+			h.log.WithField("span", res).Warn(err.Error())
+			return
+		}
+	}()
+
+	userDetails := mw.CurrentUser(c)
 
 	err = c.Request.ParseMultipartForm(32 << 20)
 	if err != nil {
@@ -154,22 +270,23 @@ func (h *UserHandler) UserUploadPhoto(c *gin.Context) {
 		return
 	}
 	sess := c.MustGet("sess").(*session.Session)
-	contentType := "image/jpeg"
 	form, err := c.MultipartForm()
 	if err != nil {
 		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
 		return
 	}
-	userDetails, err := h.userService.FetchUserByUsername(dCtx, username)
-	if err != nil {
-		err = er.New(err, er.UncaughtException).SetStatus(http.StatusBadRequest)
-		return
-	}
 	req = user.UserImages{
 		UserID: userDetails.ID,
+		Album:  strings.TrimSpace(c.PostForm("album")),
+		Tags:   splitTags(c.PostForm("tags")),
 	}
 	files := form.File["images"]
 	for _, file := range files {
+		contentType := file.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = "image/jpeg"
+		}
+		req.ContentType = contentType
 		// Open the uploaded file
 		f, err := file.Open()
 		if err != nil {
@@ -185,7 +302,7 @@ func (h *UserHandler) UserUploadPhoto(c *gin.Context) {
 			return
 		}
 		fmt.Printf("Uploaded file: %s\n", filename)
-		go h.SendMessageToSocket("uploaded successfully", filename)
+		go h.SendMessageToSocket(userDetails.Username, "uploaded successfully", filename)
 	}
 
 	res.Message = "uploaded successfully"
@@ -194,6 +311,21 @@ func (h *UserHandler) UserUploadPhoto(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
-func (h *UserHandler) SendMessageToSocket(message string, file ...string) {
-	messageChan <- fmt.Sprint(file, " ", message)
+// splitTags parses a comma separated list of tags, dropping empty and duplicate ones
+func splitTags(tags string) []string {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		res = append(res, tag)
+	}
+	return res
+}
+
+func (h *UserHandler) SendMessageToSocket(username, message string, file ...string) {
+	h.notifyService.Publish(username, fmt.Sprint(file, " ", message))
 }
@@ -0,0 +1,140 @@
+package mw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/auth"
+	"uber_fx_init_folder_structure/pkg/user"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	userKey   = "user"
+	apiKeyKey = "api_key"
+	// BearerSubprotocol is the websocket subprotocol carrying an access token,
+	// browsers can not set headers on the upgrade so the client asks for `bearer, <token>`
+	BearerSubprotocol = "bearer"
+)
+
+var (
+	errMissingToken = errors.New("missing access token")
+	errScope        = errors.New("api key is missing the scope of this route")
+	errLoginOnly    = errors.New("this route needs a login, api keys are not accepted")
+)
+
+// Authenticate verifies the access token or API key of the request and puts its user on the context.
+// The token is read from the Authorization header, the access_token query parameter
+// or the websocket subprotocol.
+func Authenticate(authService *auth.Service, apikeyService *apikey.Service, userService *user.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := accessToken(c.Request)
+		if token == "" {
+			c.Error(er.New(errMissingToken, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore())
+			c.Abort()
+			return
+		}
+		if apikey.IsKey(token) {
+			key, err := apikeyService.Authenticate(context.Background(), token)
+			if err != nil {
+				c.Error(er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore())
+				c.Abort()
+				return
+			}
+			userDetails, err := userService.FetchUserByID(context.Background(), key.UserID)
+			if err != nil {
+				c.Error(er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore())
+				c.Abort()
+				return
+			}
+			c.Set(apiKeyKey, key)
+			c.Set(userKey, userDetails)
+			c.Next()
+			return
+		}
+		claims, err := authService.VerifyAccessToken(token)
+		if err != nil {
+			c.Error(er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore())
+			c.Abort()
+			return
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			c.Error(er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore())
+			c.Abort()
+			return
+		}
+		userDetails, err := userService.FetchUserByID(context.Background(), userID)
+		if err != nil {
+			c.Error(er.New(err, er.Unauthorized).SetStatus(http.StatusUnauthorized).Ignore())
+			c.Abort()
+			return
+		}
+		c.Set(userKey, userDetails)
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user set by Authenticate
+func CurrentUser(c *gin.Context) *user.User {
+	return c.MustGet(userKey).(*user.User)
+}
+
+// ChatSession returns the chat session of the request with the client's locale
+func ChatSession(c *gin.Context, id string) user.Session {
+	session := user.Session{ID: id, Locale: Locale(c)}
+	if key, ok := c.Get(apiKeyKey); ok {
+		session.APIKey, session.Scopes = true, key.(*apikey.APIKey).Scopes
+	}
+	return session
+}
+
+// RequireScope rejects requests made with an API key that lacks scope.
+// Requests authenticated by a login are not limited.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := c.Get(apiKeyKey); ok && !key.(*apikey.APIKey).HasScope(scope) {
+			c.Error(er.New(errScope, er.Unauthorized).SetStatus(http.StatusForbidden).Ignore())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireLogin rejects requests made with an API key
+func RequireLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(apiKeyKey); ok {
+			c.Error(er.New(errLoginOnly, er.Unauthorized).SetStatus(http.StatusForbidden).Ignore())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func accessToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		protocols := websocket.Subprotocols(r)
+		if len(protocols) == 2 && protocols[0] == BearerSubprotocol {
+			return protocols[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package mw
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/ratelimit"
+	"uber_fx_init_folder_structure/pkg/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// RateLimit counts the request against the named limit of its IP, user and API key,
+// a refused request is answered with 429 and a Retry-After header
+func RateLimit(limiter *ratelimit.Service, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res := limiter.Allow(c, name, RateLimitSubjects(c)...)
+		if !res.Allowed {
+			c.Header("Retry-After", RetryAfterSeconds(res))
+			c.Error(er.New(errRateLimited, er.TooManyRequests).SetStatus(http.StatusTooManyRequests).Ignore())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitSubjects returns who the request is counted against, requests made with an
+// API key count against the key and its owner
+func RateLimitSubjects(c *gin.Context) []ratelimit.Subject {
+	subjects := []ratelimit.Subject{{Kind: ratelimit.KindIP, ID: c.ClientIP()}}
+	if u, ok := c.Get(userKey); ok {
+		subjects = append(subjects, ratelimit.Subject{Kind: ratelimit.KindUser, ID: strconv.Itoa(u.(*user.User).ID)})
+	}
+	if key, ok := c.Get(apiKeyKey); ok {
+		subjects = append(subjects, ratelimit.Subject{Kind: ratelimit.KindAPIKey, ID: strconv.Itoa(key.(*apikey.APIKey).ID)})
+	}
+	return subjects
+}
+
+// RetryAfterSeconds rounds the wait up to whole seconds as Retry-After expects
+func RetryAfterSeconds(res ratelimit.Result) string {
+	return strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds())))
+}
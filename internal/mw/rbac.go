@@ -0,0 +1,32 @@
+package mw
+
+import (
+	"errors"
+	"net/http"
+	"uber_fx_init_folder_structure/er"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/utils/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errPermission = errors.New("your roles do not grant the permission of this route")
+
+// RequirePermission rejects users whose roles do not grant the permission,
+// it must run after Authenticate
+func RequirePermission(rbacService *rbac.Service, permission types.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, err := rbacService.HasPermission(c, CurrentUser(c).ID, permission)
+		if err != nil {
+			c.Error(er.New(err, er.UncaughtException).SetStatus(http.StatusInternalServerError).Ignore())
+			c.Abort()
+			return
+		}
+		if !ok {
+			c.Error(er.New(errPermission, er.Unauthorized).SetStatus(http.StatusForbidden).Ignore())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"uber_fx_init_folder_structure/internal/mw"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/utils/types"
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
@@ -11,7 +13,81 @@ func v1Routes(router *gin.RouterGroup, awsSession *session.Session, o *Options)
 	r := router.Group("/v1/")
 	// middlewares
 	r.Use(mw.ErrorHandlerX(o.Log))
-	r.PUT("/user_registration", o.UserHandler.CreateUser)
-	r.GET("/ws/user_chat", o.UserHandler.ChatWithBot)
-	r.POST("/upload_photos", mw.AWSSessionAttach(awsSession), o.UserHandler.UserUploadPhoto)
+	auth := mw.RateLimit(o.RateLimiter, "auth")
+	r.PUT("/user_registration", auth, o.UserHandler.CreateUser)
+	r.POST("/login", auth, o.AuthHandler.Login)
+	r.POST("/token/refresh", auth, o.AuthHandler.RefreshToken)
+	r.POST("/logout", auth, o.AuthHandler.Logout)
+	r.GET("/sso/login", auth, o.AuthHandler.SSOLogin)
+	r.GET("/sso/callback", auth, o.AuthHandler.SSOCallback)
+
+	// routes below require an access token or an api key with the route's scope
+	a := r.Group("/", mw.Authenticate(o.AuthService, o.APIKeyService, o.UserService), mw.RateLimit(o.RateLimiter, "api"))
+	// linking a single sign-on identity needs a login, it ends at /sso/callback like a sign in
+	a.GET("/sso/link", mw.RequireLogin(), o.AuthHandler.SSOLink)
+	// every websocket message counts against the chat limit
+	a.GET("/ws/user_chat", mw.RequireScope(apikey.ScopeChat), o.UserHandler.ChatWithBot)
+	a.POST("/chat", mw.RequireScope(apikey.ScopeChat), mw.RateLimit(o.RateLimiter, "chat"), o.UserHandler.Chat)
+	a.POST("/upload_photos", mw.RequireScope(apikey.ScopePhotosWrite), mw.RateLimit(o.RateLimiter, "upload"), mw.AWSSessionAttach(awsSession), o.UserHandler.UserUploadPhoto)
+	a.GET("/users/:username/photos", mw.RequireScope(apikey.ScopePhotosRead), o.UserHandler.ListPhotos)
+	a.GET("/photos/trash", mw.RequireScope(apikey.ScopePhotosRead), o.UserHandler.ListTrash)
+	a.DELETE("/photos/:id", mw.RequireScope(apikey.ScopePhotosWrite), o.UserHandler.TrashPhoto)
+	a.POST("/photos/:id/restore", mw.RequireScope(apikey.ScopePhotosWrite), o.UserHandler.RestorePhoto)
+	a.POST("/grants", mw.RequireScope(apikey.ScopePhotosWrite), o.UserHandler.SharePhotos)
+	a.GET("/grants", mw.RequireScope(apikey.ScopePhotosRead), o.UserHandler.ListGrants)
+	a.DELETE("/grants/:id", mw.RequireScope(apikey.ScopePhotosWrite), o.UserHandler.RevokeGrant)
+	a.POST("/share_links", mw.RequireScope(apikey.ScopePhotosWrite), o.ShareHandler.CreateShareLink)
+	a.GET("/share_links", mw.RequireScope(apikey.ScopePhotosRead), o.ShareHandler.ListShareLinks)
+	a.DELETE("/share_links/:id", mw.RequireScope(apikey.ScopePhotosWrite), o.ShareHandler.RevokeShareLink)
+	a.GET("/usage", mw.RequireScope(apikey.ScopeChat), o.UsageHandler.Report)
+	a.POST("/messages/:id/feedback", mw.RequireScope(apikey.ScopeChat), o.FeedbackHandler.SaveFeedback)
+	a.GET("/memories", mw.RequireScope(apikey.ScopeChat), o.MemoryHandler.ListMemories)
+	a.DELETE("/memories", mw.RequireScope(apikey.ScopeChat), o.MemoryHandler.DeleteMemories)
+	a.DELETE("/memories/:id", mw.RequireScope(apikey.ScopeChat), o.MemoryHandler.DeleteMemory)
+	a.POST("/exports", mw.RequireScope(apikey.ScopeExports), o.ExportHandler.CreateExport)
+	a.GET("/exports/:id", mw.RequireScope(apikey.ScopeExports), o.ExportHandler.FetchExport)
+
+	// keys can not create or revoke keys
+	a.POST("/api_keys", mw.RequireLogin(), o.APIKeyHandler.CreateAPIKey)
+	a.GET("/api_keys", mw.RequireLogin(), o.APIKeyHandler.ListAPIKeys)
+	a.DELETE("/api_keys/:id", mw.RequireLogin(), o.APIKeyHandler.RevokeAPIKey)
+}
+
+// adminRoutes need a login, api keys are never accepted, and the permission of each route
+func adminRoutes(router *gin.RouterGroup, o *Options) {
+	r := router.Group("/v1/admin/")
+	r.Use(mw.ErrorHandlerX(o.Log), mw.Authenticate(o.AuthService, o.APIKeyService, o.UserService), mw.RequireLogin(), mw.RateLimit(o.RateLimiter, "api"))
+	users := mw.RequirePermission(o.RBACService, types.USERMANAGEMENT)
+	r.GET("/roles", users, o.AdminHandler.ListRoles)
+	r.POST("/roles", users, o.AdminHandler.SaveRole)
+	r.GET("/users/:username/roles", users, o.AdminHandler.ListUserRoles)
+	r.PUT("/users/:username/roles/:role", users, o.AdminHandler.AssignRole)
+	r.DELETE("/users/:username/roles/:role", users, o.AdminHandler.UnassignRole)
+
+	jobs := mw.RequirePermission(o.RBACService, types.QUEUEMANAGEMENT)
+	r.GET("/queue", jobs, o.AdminHandler.QueueStats)
+	r.POST("/reconcile", jobs, o.AdminHandler.Reconcile)
+
+	r.GET("/usage", mw.RequirePermission(o.RBACService, types.USAGEREPORTS), o.UsageHandler.UsersReport)
+
+	prompts := mw.RequirePermission(o.RBACService, types.PROMPTMANAGEMENT)
+	r.GET("/prompts/:name", prompts, o.PromptHandler.ListVersions)
+	r.POST("/prompts/:name", prompts, o.PromptHandler.CreateVersion)
+	r.POST("/prompts/:name/preview", prompts, mw.RateLimit(o.RateLimiter, "chat"), o.PromptHandler.Preview)
+	r.PUT("/prompts/:name/active", prompts, o.PromptHandler.Activate)
+	r.POST("/prompts/:name/rollback", prompts, o.PromptHandler.Rollback)
+	r.GET("/experiments", prompts, o.ExperimentHandler.ListExperiments)
+	r.POST("/experiments", prompts, o.ExperimentHandler.StartExperiment)
+	r.POST("/experiments/:id/stop", prompts, o.ExperimentHandler.StopExperiment)
+	r.GET("/experiments/:id/report", prompts, o.ExperimentHandler.Report)
+
+	r.GET("/feedback", mw.RequirePermission(o.RBACService, types.FEEDBACKREVIEW), o.FeedbackHandler.ExportFeedback)
+}
+
+// shareRoutes are the public pages of share links, the token is the only credential
+func shareRoutes(router *gin.RouterGroup, o *Options) {
+	r := router.Group("/s/")
+	r.Use(mw.ErrorHandlerX(o.Log), mw.RateLimit(o.RateLimiter, "share"))
+	r.GET("/:token", o.ShareHandler.OpenShareLink)
+	r.POST("/:token", o.ShareHandler.OpenShareLink)
 }
@@ -1,10 +1,18 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"uber_fx_init_folder_structure/internal/handler"
 	"uber_fx_init_folder_structure/internal/mw/aws"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/auth"
+	"uber_fx_init_folder_structure/pkg/ratelimit"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/user"
 	"uber_fx_init_folder_structure/utils"
 
 	"github.com/gin-gonic/gin"
@@ -31,23 +39,63 @@ const (
 type Options struct {
 	fx.In
 
-	Config      *viper.Viper
-	Log         *logrus.Logger
-	PostgresDB  *pg.DB      `name:"userdb"`
-	Redis       *redis.Pool `name:"redisWorker"`
-	UserHandler *handler.UserHandler
+	Config            *viper.Viper
+	Log               *logrus.Logger
+	PostgresDB        *pg.DB      `name:"userdb"`
+	Redis             *redis.Pool `name:"redisWorker"`
+	UserHandler       *handler.UserHandler
+	ExportHandler     *handler.ExportHandler
+	AuthHandler       *handler.AuthHandler
+	APIKeyHandler     *handler.APIKeyHandler
+	ShareHandler      *handler.ShareHandler
+	AdminHandler      *handler.AdminHandler
+	UsageHandler      *handler.UsageHandler
+	PromptHandler     *handler.PromptHandler
+	ExperimentHandler *handler.ExperimentHandler
+	FeedbackHandler   *handler.FeedbackHandler
+	MemoryHandler     *handler.MemoryHandler
+	AuthService       *auth.Service
+	APIKeyService     *apikey.Service
+	UserService       *user.Service
+	RBACService       *rbac.Service
+	RateLimiter       *ratelimit.Service
 }
 
-// Run starts the mainserver REST API server
-func Run(o Options) {
-	router := SetupRouter(&o)
-	router.Run(fmt.Sprintf("%s:%s", addr, o.Config.GetString("port")))
+// Run starts the mainserver REST API server once every module is invoked and shuts it down
+// with the app, requests in flight get until the fx stop timeout to finish
+func Run(lc fx.Lifecycle, o Options) {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%s", addr, o.Config.GetString("port")),
+		Handler: SetupRouter(&o),
+	}
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			o.Log.Info("listening on " + srv.Addr)
+			go func() {
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					o.Log.Fatal("server: " + err.Error())
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return srv.Shutdown(ctx)
+		},
+	})
 }
 
 // SetupRouter creates gin router and registers all user routes to it
 func SetupRouter(o *Options) (router *gin.Engine) {
 	gin.SetMode(gin.ReleaseMode)
 	router = gin.New()
+	// the client IP is the peer address unless the peer is a trusted proxy
+	if err := router.SetTrustedProxies(strings.Fields(o.Config.GetString(utils.TrustedProxies))); err != nil {
+		o.Log.WithField("error", err.Error()).Fatal("invalid trusted_proxies")
+	}
 
 	// Logs all panic to error log
 	router.Use(ginlogrus.Logger(o.Log), gin.Recovery())
@@ -63,6 +111,8 @@ func SetupRouter(o *Options) (router *gin.Engine) {
 	rootRouter := router.Group("/")
 
 	v1Routes(rootRouter, awsSession, o)
+	adminRoutes(rootRouter, o)
+	shareRoutes(rootRouter, o)
 
 	return
 }
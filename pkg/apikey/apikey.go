@@ -0,0 +1,53 @@
+package apikey
+
+import (
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the API key service
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+// scopes limit the routes a key can call, keys can never manage other keys
+const (
+	ScopePhotosRead  = "photos:read"
+	ScopePhotosWrite = "photos:write"
+	ScopeChat        = "chat"
+	ScopeExports     = "exports"
+)
+
+// Scopes lists every scope a key can be given
+var Scopes = []string{ScopePhotosRead, ScopePhotosWrite, ScopeChat, ScopeExports}
+
+// Prefix starts every key so that it can be told apart from an access token
+const Prefix = "pk_"
+
+// APIKey is a long lived credential for scripts, only the hash of the key is stored
+type APIKey struct {
+	tableName  struct{}  `pg:"api_keys,discard_unknown_columns"`
+	ID         int       `json:"id" pg:"id,pk"`
+	UserID     int       `json:"-" pg:"user_id"`
+	Name       string    `json:"name" pg:"name"`
+	Hint       string    `json:"hint" pg:"hint"`
+	KeyHash    string    `json:"-" pg:"key_hash,unique"`
+	Scopes     []string  `json:"scopes" pg:"scopes,array"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty" pg:"last_used_at"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty" pg:"revoked_at"`
+	CreatedAt  time.Time `json:"created_at" pg:"created_at"`
+}
+
+// HasScope reports whether the key may call routes requiring scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
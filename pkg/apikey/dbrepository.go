@@ -0,0 +1,92 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	createKey(context.Context, *APIKey) error
+	fetchKeyByHash(context.Context, string) (*APIKey, error)
+	retrieveKeys(context.Context, int) ([]APIKey, error)
+	revokeKey(context.Context, int, int) error
+	touchKey(context.Context, int, time.Time) error
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+func (r *PGRepo) createKey(ctx context.Context, key *APIKey) error {
+	_, err := r.db.ModelContext(ctx, key).Insert()
+	return err
+}
+
+func (r *PGRepo) fetchKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	key := &APIKey{}
+	err := r.db.ModelContext(ctx, key).
+		Where("key_hash = ?", hash).
+		Where("revoked_at IS NULL").
+		Select()
+	return key, err
+}
+
+func (r *PGRepo) retrieveKeys(ctx context.Context, userID int) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := r.db.ModelContext(ctx, &keys).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Order("id").
+		Select()
+	return keys, err
+}
+
+func (r *PGRepo) revokeKey(ctx context.Context, userID, id int) error {
+	res, err := r.db.ModelContext(ctx, (*APIKey)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func (r *PGRepo) touchKey(ctx context.Context, id int, at time.Time) error {
+	_, err := r.db.ModelContext(ctx, (*APIKey)(nil)).
+		Set("last_used_at = ?", at).
+		Where("id = ?", id).
+		Update()
+	return err
+}
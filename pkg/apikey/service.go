@@ -0,0 +1,135 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// touchEvery limits how often last_used_at is written for a busy key
+const touchEvery = time.Minute
+
+var (
+	ErrInvalidKey   = errors.New("invalid or revoked api key")
+	ErrInvalidScope = errors.New("invalid scope")
+	ErrNoScopes     = errors.New("an api key needs at least one scope")
+)
+
+type Service struct {
+	log  *logrus.Logger
+	Repo Repository
+}
+
+// NewService returns an API key service object.
+func NewService(log *logrus.Logger, Repo Repository) *Service {
+	return &Service{
+		log:  log,
+		Repo: Repo,
+	}
+}
+
+// Create generates a key for the user. The returned key is shown once, only its hash is stored.
+func (s *Service) Create(ctx context.Context, userID int, name string, scopes []string) (string, *APIKey, error) {
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	raw := Prefix + base64.RawURLEncoding.EncodeToString(b)
+	key := &APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(name),
+		Hint:      raw[:len(Prefix)+6],
+		KeyHash:   hash(raw),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if err := s.Repo.createKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return raw, key, nil
+}
+
+// List returns the keys of the user that are not revoked
+func (s *Service) List(ctx context.Context, userID int) ([]APIKey, error) {
+	return s.Repo.retrieveKeys(ctx, userID)
+}
+
+// Revoke disables a key of the user, it returns pg.ErrNoRows when the user has no such key
+func (s *Service) Revoke(ctx context.Context, userID, id int) error {
+	return s.Repo.revokeKey(ctx, userID, id)
+}
+
+// Authenticate returns the key matching raw and records its use
+func (s *Service) Authenticate(ctx context.Context, raw string) (*APIKey, error) {
+	if !IsKey(raw) {
+		return nil, ErrInvalidKey
+	}
+	key, err := s.Repo.fetchKeyByHash(ctx, hash(raw))
+	if err == pg.ErrNoRows {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if now.Sub(key.LastUsedAt) > touchEvery {
+		if err := s.Repo.touchKey(ctx, key.ID, now); err != nil {
+			s.log.WithField("api_key_id", key.ID).Warn(err.Error())
+		}
+		key.LastUsedAt = now
+	}
+	return key, nil
+}
+
+// IsKey reports whether a bearer token looks like an API key rather than an access token
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if seen[scope] {
+			continue
+		}
+		if !validScope(scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		seen[scope] = true
+		res = append(res, scope)
+	}
+	if len(res) == 0 {
+		return nil, ErrNoScopes
+	}
+	return res, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// hash is enough for keys, they are random and long unlike passwords
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
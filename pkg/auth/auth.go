@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/fx"
+)
+
+// Module provides the access and refresh token service
+var Module = fx.Options(
+	fx.Provide(
+		NewService,
+	),
+)
+
+type (
+	// Claims are the claims of an access token, the subject is the user id
+	Claims struct {
+		Username string `json:"username"`
+		jwt.RegisteredClaims
+	}
+	// Tokens is the response of a login or a refresh
+	Tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		// ExpiresIn is the lifetime of the access token in seconds
+		ExpiresIn int `json:"expires_in"`
+	}
+)
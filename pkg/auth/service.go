@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/pkg/user"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// refresh tokens are `<family>.<secret>`, every rotation keeps the family so that
+// reusing an already rotated token revokes the whole family
+const (
+	refreshTokenKey  = "refresh:token:"
+	refreshUsedKey   = "refresh:used:"
+	refreshFamilyKey = "refresh:family:"
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid or expired token")
+	ErrRefreshReused = errors.New("refresh token was already used, please log in again")
+)
+
+// rotateScript swaps a refresh token for a new one of the same family
+var rotateScript = redis.NewScript(4, `
+local uid = redis.call('GET', KEYS[1])
+if not uid then return false end
+redis.call('DEL', KEYS[1])
+redis.call('SET', KEYS[2], uid, 'EX', ARGV[1])
+redis.call('SET', KEYS[3], uid, 'EX', ARGV[1])
+redis.call('SET', KEYS[4], ARGV[2], 'EX', ARGV[1])
+return uid`)
+
+// NewServiceIn is function param struct of func `NewService`
+type NewServiceIn struct {
+	fx.In
+
+	Conf *viper.Viper
+	Log  *logrus.Logger
+	Pool *redis.Pool `name:"redisWorker"`
+}
+
+type Service struct {
+	conf   *viper.Viper
+	log    *logrus.Logger
+	pool   *redis.Pool
+	secret []byte
+}
+
+// NewService returns a token service object.
+func NewService(i NewServiceIn) (*Service, error) {
+	secret := []byte(i.Conf.GetString(utils.JWTSecret))
+	if len(secret) == 0 {
+		// tokens will not survive a restart nor work across instances
+		i.Log.Warn("jwt_secret is not set, using a random secret")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &Service{
+		conf:   i.Conf,
+		log:    i.Log,
+		pool:   i.Pool,
+		secret: secret,
+	}, nil
+}
+
+// IssueTokens starts a new refresh token family for the user
+func (s *Service) IssueTokens(ctx context.Context, u *user.User) (*Tokens, error) {
+	return s.issue(ctx, u.ID, u.Username, uuid.NewString())
+}
+
+// Refresh rotates a refresh token, the old one can not be used again
+func (s *Service) Refresh(ctx context.Context, refreshToken string, fetchUser func(context.Context, int) (*user.User, error)) (*Tokens, error) {
+	family, _, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	newToken, err := newRefreshToken(family)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ttl := int(s.refreshTTL().Seconds())
+	uid, err := redis.Int(rotateScript.Do(conn,
+		refreshTokenKey+hash(refreshToken),
+		refreshTokenKey+hash(newToken),
+		refreshUsedKey+hash(refreshToken),
+		refreshFamilyKey+family,
+		ttl, hash(newToken),
+	))
+	if err == redis.ErrNil {
+		reused, err := redis.Bool(conn.Do("EXISTS", refreshUsedKey+hash(refreshToken)))
+		if err != nil {
+			return nil, err
+		}
+		if reused {
+			s.log.WithField("family", family).Warn("refresh token reused, revoking its family")
+			if err := s.revokeFamily(conn, family); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshReused
+		}
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := fetchUser(ctx, uid)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	access, err := s.accessToken(u.ID, u.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: newToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL().Seconds()),
+	}, nil
+}
+
+// Revoke invalidates a refresh token and every token rotated from the same login
+func (s *Service) Revoke(ctx context.Context, refreshToken string) error {
+	family, _, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return s.revokeFamily(conn, family)
+}
+
+// VerifyAccessToken checks the signature and expiry of an access token
+func (s *Service) VerifyAccessToken(token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.conf.GetString(utils.JWTIssuer)),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// UserID returns the id of the user the token was issued to
+func (c *Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+func (s *Service) issue(ctx context.Context, userID int, username, family string) (*Tokens, error) {
+	access, err := s.accessToken(userID, username)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := newRefreshToken(family)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ttl := int(s.refreshTTL().Seconds())
+	conn.Send("MULTI")
+	conn.Send("SET", refreshTokenKey+hash(refresh), userID, "EX", ttl)
+	conn.Send("SET", refreshFamilyKey+family, hash(refresh), "EX", ttl)
+	if _, err := conn.Do("EXEC"); err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL().Seconds()),
+	}, nil
+}
+
+func (s *Service) accessToken(userID int, username string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.conf.GetString(utils.JWTIssuer),
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL())),
+			ID:        uuid.NewString(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+func (s *Service) revokeFamily(conn redis.Conn, family string) error {
+	current, err := redis.String(conn.Do("GET", refreshFamilyKey+family))
+	if err == redis.ErrNil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("DEL", refreshTokenKey+current, refreshFamilyKey+family)
+	return err
+}
+
+func (s *Service) accessTTL() time.Duration {
+	return s.conf.GetDuration(utils.JWTAccessTTL)
+}
+
+func (s *Service) refreshTTL() time.Duration {
+	return s.conf.GetDuration(utils.JWTRefreshTTL)
+}
+
+func newRefreshToken(family string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return family + "." + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hash keeps raw refresh tokens out of redis
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package conversation
+
+import (
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/fx"
+)
+
+// Module provides the store of chat messages
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+// roles of stored messages
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	// RoleToolCall is an answer of the model calling tools, it is sent to the model as an assistant message
+	RoleToolCall = "tool_call"
+	// RoleTool is the result of a tool call
+	RoleTool = "tool"
+)
+
+type (
+	// Message is a message of a chat session, with the prompt and experiment variant that answered it
+	Message struct {
+		tableName struct{} `pg:"chat_messages,alias:message,discard_unknown_columns"`
+		ID        int      `json:"id" pg:"id,pk"`
+		UserID    int      `json:"-" pg:"user_id"`
+		SessionID string   `json:"session_id" pg:"session_id"`
+		Role      string   `json:"role" pg:"role"`
+		Content   string   `json:"content" pg:"content"`
+		// ToolCalls are the calls of a RoleToolCall message, ToolCallID and Name are the call and the tool
+		// a RoleTool message answers
+		ToolCalls  []openai.ToolCall `json:"tool_calls,omitempty" pg:"tool_calls,type:jsonb"`
+		ToolCallID string            `json:"tool_call_id,omitempty" pg:"tool_call_id"`
+		Name       string            `json:"name,omitempty" pg:"name"`
+		// PromptVersion is the version of the system prompt, 0 for the built-in one
+		PromptVersion int       `json:"prompt_version" pg:"prompt_version,use_zero"`
+		ExperimentID  int       `json:"experiment_id,omitempty" pg:"experiment_id"`
+		Variant       string    `json:"variant,omitempty" pg:"variant"`
+		CreatedAt     time.Time `json:"created_at" pg:"created_at"`
+	}
+	// Summary is the rolling summary of the older turns of a chat session, the messages up to
+	// UpToID are sent to the model as the summary rather than verbatim
+	Summary struct {
+		tableName struct{} `pg:"chat_summaries,alias:summary,discard_unknown_columns"`
+		UserID    int      `json:"-" pg:"user_id,pk,type:bigint"`
+		SessionID string   `json:"session_id" pg:"session_id,pk"`
+		Content   string   `json:"content" pg:"content"`
+		UpToID    int      `json:"up_to_id" pg:"up_to_id,use_zero"`
+		// Turns is how many turns the summary covers
+		Turns int `json:"turns" pg:"turns,use_zero"`
+		// ToolResults are the latest result of each tool called in the summarized turns
+		ToolResults []ToolResult `json:"tool_results" pg:"tool_results,type:jsonb"`
+		UpdatedAt   time.Time    `json:"updated_at" pg:"updated_at"`
+	}
+	// ToolResult is the result of a tool kept verbatim with a summary
+	ToolResult struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+	}
+	// Feedback is the rating a user gave an answer of the bot, a user rates an answer once
+	Feedback struct {
+		tableName struct{} `pg:"message_feedback,alias:feedback,discard_unknown_columns"`
+		ID        int      `json:"id" pg:"id,pk"`
+		MessageID int      `json:"message_id" pg:"message_id"`
+		UserID    int      `json:"-" pg:"user_id"`
+		Rating    string   `json:"rating" pg:"rating"`
+		Comment   string   `json:"comment,omitempty" pg:"comment"`
+		Reasons   []string `json:"reasons" pg:"reasons,array"`
+		// PromptVersion, ExperimentID and Variant are copied from the message
+		PromptVersion int       `json:"prompt_version" pg:"prompt_version,use_zero"`
+		ExperimentID  int       `json:"experiment_id,omitempty" pg:"experiment_id"`
+		Variant       string    `json:"variant,omitempty" pg:"variant"`
+		CreatedAt     time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at" pg:"updated_at"`
+	}
+	// FeedbackExport is a feedback with the answer it rates and the message it answered
+	FeedbackExport struct {
+		ID            int       `json:"id"`
+		MessageID     int       `json:"message_id"`
+		SessionID     string    `json:"session_id"`
+		Username      string    `json:"username"`
+		Rating        string    `json:"rating"`
+		Comment       string    `json:"comment,omitempty"`
+		Reasons       []string  `json:"reasons" pg:",array"`
+		PromptVersion int       `json:"prompt_version"`
+		ExperimentID  int       `json:"experiment_id,omitempty"`
+		Variant       string    `json:"variant,omitempty"`
+		Question      string    `json:"question"`
+		Answer        string    `json:"answer"`
+		AnsweredAt    time.Time `json:"answered_at"`
+		CreatedAt     time.Time `json:"created_at"`
+	}
+	// VariantCount counts the sessions, answers and ratings of a variant of an experiment
+	VariantCount struct {
+		Variant    string `json:"variant"`
+		Sessions   int    `json:"sessions"`
+		Messages   int    `json:"messages"`
+		ThumbsUp   int    `json:"thumbs_up"`
+		ThumbsDown int    `json:"thumbs_down"`
+	}
+)
+
+// ratings of an answer
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// Reasons are the tags a user can give with a rating
+var Reasons = []string{"incorrect", "unhelpful", "too_long", "too_short", "wrong_language", "wrong_tool", "unsafe", "other"}
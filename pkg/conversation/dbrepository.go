@@ -0,0 +1,166 @@
+package conversation
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	insertMessages(context.Context, []*Message) error
+	fetchMessage(context.Context, int) (*Message, error)
+	retrieveHistory(context.Context, int, string, int, int) ([]Message, error)
+	retrieveMessages(context.Context, int, string, int, int, int) ([]Message, error)
+	fetchSummary(context.Context, int, string) (*Summary, error)
+	upsertSummary(context.Context, *Summary) error
+	upsertFeedback(context.Context, *Feedback) error
+	retrieveFeedback(context.Context, time.Time, time.Time, int, int) ([]FeedbackExport, error)
+	countByVariant(context.Context, int) ([]VariantCount, error)
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+func (r *PGRepo) insertMessages(ctx context.Context, messages []*Message) error {
+	_, err := r.db.ModelContext(ctx, &messages).Insert()
+	return err
+}
+
+func (r *PGRepo) fetchMessage(ctx context.Context, id int) (*Message, error) {
+	message := &Message{}
+	err := r.db.ModelContext(ctx, message).
+		Where("id = ?", id).
+		Select()
+	return message, err
+}
+
+// retrieveHistory returns the latest limit messages of the session after the ID, oldest first
+func (r *PGRepo) retrieveHistory(ctx context.Context, userID int, sessionID string, afterID, limit int) ([]Message, error) {
+	messages := []Message{}
+	err := r.db.ModelContext(ctx, &messages).
+		Where("user_id = ?", userID).
+		Where("session_id = ?", sessionID).
+		Where("id > ?", afterID).
+		Order("id DESC").
+		Limit(limit).
+		Select()
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, err
+}
+
+// retrieveMessages returns up to limit messages of the session after afterID and before beforeID, oldest first
+func (r *PGRepo) retrieveMessages(ctx context.Context, userID int, sessionID string, afterID, beforeID, limit int) ([]Message, error) {
+	messages := []Message{}
+	err := r.db.ModelContext(ctx, &messages).
+		Where("user_id = ?", userID).
+		Where("session_id = ?", sessionID).
+		Where("id > ?", afterID).
+		Where("id < ?", beforeID).
+		Order("id ASC").
+		Limit(limit).
+		Select()
+	return messages, err
+}
+
+func (r *PGRepo) fetchSummary(ctx context.Context, userID int, sessionID string) (*Summary, error) {
+	summary := &Summary{}
+	err := r.db.ModelContext(ctx, summary).
+		Where("user_id = ?", userID).
+		Where("session_id = ?", sessionID).
+		Select()
+	return summary, err
+}
+
+// upsertSummary stores the summary of the session, replacing the previous one
+func (r *PGRepo) upsertSummary(ctx context.Context, summary *Summary) error {
+	_, err := r.db.ModelContext(ctx, summary).
+		OnConflict("(user_id, session_id) DO UPDATE").
+		Set("content = EXCLUDED.content").
+		Set("up_to_id = EXCLUDED.up_to_id").
+		Set("turns = EXCLUDED.turns").
+		Set("tool_results = EXCLUDED.tool_results").
+		Set("updated_at = EXCLUDED.updated_at").
+		Insert()
+	return err
+}
+
+// upsertFeedback stores the feedback, replacing the rating the user gave the message before
+func (r *PGRepo) upsertFeedback(ctx context.Context, feedback *Feedback) error {
+	_, err := r.db.ModelContext(ctx, feedback).
+		OnConflict("(message_id, user_id) DO UPDATE").
+		Set("rating = EXCLUDED.rating").
+		Set("comment = EXCLUDED.comment").
+		Set("reasons = EXCLUDED.reasons").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("id, created_at").
+		Insert()
+	return err
+}
+
+// retrieveFeedback returns up to limit feedbacks given between from and to after the ID
+func (r *PGRepo) retrieveFeedback(ctx context.Context, from, to time.Time, afterID, limit int) ([]FeedbackExport, error) {
+	exports := []FeedbackExport{}
+	err := r.db.ModelContext(ctx, (*Feedback)(nil)).
+		ColumnExpr("?TableAlias.id, ?TableAlias.message_id, ?TableAlias.rating, ?TableAlias.comment, ?TableAlias.reasons").
+		ColumnExpr("?TableAlias.prompt_version, ?TableAlias.experiment_id, ?TableAlias.variant, ?TableAlias.created_at").
+		ColumnExpr("m.session_id, m.content AS answer, m.created_at AS answered_at, u.username").
+		// the message of the user the answer replied to
+		ColumnExpr(`(SELECT q.content FROM chat_messages AS q
+			WHERE q.user_id = m.user_id AND q.session_id = m.session_id AND q.role = ? AND q.id < m.id
+			ORDER BY q.id DESC LIMIT 1) AS question`, RoleUser).
+		Join("JOIN chat_messages AS m ON m.id = ?TableAlias.message_id").
+		Join("LEFT JOIN users AS u ON u.id = ?TableAlias.user_id").
+		Where("?TableAlias.created_at >= ?", from).
+		Where("?TableAlias.created_at < ?", to).
+		Where("?TableAlias.id > ?", afterID).
+		Order("feedback.id").
+		Limit(limit).
+		Select(&exports)
+	return exports, err
+}
+
+// countByVariant counts the answers of each variant of the experiment and their ratings
+func (r *PGRepo) countByVariant(ctx context.Context, experimentID int) ([]VariantCount, error) {
+	counts := []VariantCount{}
+	err := r.db.ModelContext(ctx, (*Message)(nil)).
+		ColumnExpr("?TableAlias.variant").
+		ColumnExpr("COUNT(DISTINCT ?TableAlias.session_id) AS sessions").
+		ColumnExpr("COUNT(*) AS messages").
+		ColumnExpr("COUNT(f.id) FILTER (WHERE f.rating = ?) AS thumbs_up", RatingUp).
+		ColumnExpr("COUNT(f.id) FILTER (WHERE f.rating = ?) AS thumbs_down", RatingDown).
+		Join("LEFT JOIN message_feedback AS f ON f.message_id = ?TableAlias.id").
+		Where("?TableAlias.experiment_id = ?", experimentID).
+		Where("?TableAlias.role = ?", RoleAssistant).
+		GroupExpr("?TableAlias.variant").
+		OrderExpr("?TableAlias.variant").
+		Select(&counts)
+	return counts, err
+}
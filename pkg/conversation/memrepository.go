@@ -0,0 +1,186 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// MemRepo keeps chat messages, summaries and feedback in memory, it backs tests that run without postgres
+type MemRepo struct {
+	mu        sync.Mutex
+	messages  []Message
+	summaries map[string]Summary
+	feedback  []Feedback
+}
+
+// NewMemRepository returns an empty in-memory persistence layer
+func NewMemRepository() Repository {
+	return &MemRepo{summaries: map[string]Summary{}}
+}
+
+func (r *MemRepo) insertMessages(ctx context.Context, messages []*Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, message := range messages {
+		message.ID = len(r.messages) + 1
+		r.messages = append(r.messages, *message)
+	}
+	return nil
+}
+
+func (r *MemRepo) fetchMessage(ctx context.Context, id int) (*Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id <= 0 || id > len(r.messages) {
+		return &Message{}, pg.ErrNoRows
+	}
+	message := r.messages[id-1]
+	return &message, nil
+}
+
+// retrieveHistory returns the latest limit messages of the session after the ID, oldest first
+func (r *MemRepo) retrieveHistory(ctx context.Context, userID int, sessionID string, afterID, limit int) ([]Message, error) {
+	messages, _ := r.retrieveMessages(ctx, userID, sessionID, afterID, math.MaxInt, math.MaxInt)
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+// retrieveMessages returns up to limit messages of the session after afterID and before beforeID, oldest first
+func (r *MemRepo) retrieveMessages(ctx context.Context, userID int, sessionID string, afterID, beforeID, limit int) ([]Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := []Message{}
+	for _, m := range r.messages {
+		if len(messages) == limit {
+			break
+		}
+		if m.UserID == userID && m.SessionID == sessionID && m.ID > afterID && m.ID < beforeID {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (r *MemRepo) fetchSummary(ctx context.Context, userID int, sessionID string) (*Summary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summary, ok := r.summaries[summaryKey(userID, sessionID)]
+	if !ok {
+		return &Summary{}, pg.ErrNoRows
+	}
+	return &summary, nil
+}
+
+// upsertSummary stores the summary of the session, replacing the previous one
+func (r *MemRepo) upsertSummary(ctx context.Context, summary *Summary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summaries[summaryKey(summary.UserID, summary.SessionID)] = *summary
+	return nil
+}
+
+func summaryKey(userID int, sessionID string) string {
+	return fmt.Sprintf("%d:%s", userID, sessionID)
+}
+
+// upsertFeedback stores the feedback, replacing the rating the user gave the message before
+func (r *MemRepo) upsertFeedback(ctx context.Context, feedback *Feedback) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, f := range r.feedback {
+		if f.MessageID == feedback.MessageID && f.UserID == feedback.UserID {
+			feedback.ID, feedback.CreatedAt = f.ID, f.CreatedAt
+			r.feedback[i] = *feedback
+			return nil
+		}
+	}
+	feedback.ID = len(r.feedback) + 1
+	r.feedback = append(r.feedback, *feedback)
+	return nil
+}
+
+// retrieveFeedback returns up to limit feedbacks given between from and to after the ID,
+// the usernames are in the users table and left empty
+func (r *MemRepo) retrieveFeedback(ctx context.Context, from, to time.Time, afterID, limit int) ([]FeedbackExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exports := []FeedbackExport{}
+	for _, f := range r.feedback {
+		if len(exports) == limit {
+			break
+		}
+		if f.ID <= afterID || f.CreatedAt.Before(from) || !f.CreatedAt.Before(to) {
+			continue
+		}
+		answer := r.messages[f.MessageID-1]
+		export := FeedbackExport{
+			ID:            f.ID,
+			MessageID:     f.MessageID,
+			SessionID:     answer.SessionID,
+			Rating:        f.Rating,
+			Comment:       f.Comment,
+			Reasons:       f.Reasons,
+			PromptVersion: f.PromptVersion,
+			ExperimentID:  f.ExperimentID,
+			Variant:       f.Variant,
+			Answer:        answer.Content,
+			AnsweredAt:    answer.CreatedAt,
+			CreatedAt:     f.CreatedAt,
+		}
+		// the message of the user the answer replied to
+		for i := f.MessageID - 2; i >= 0; i-- {
+			q := r.messages[i]
+			if q.UserID == answer.UserID && q.SessionID == answer.SessionID && q.Role == RoleUser {
+				export.Question = q.Content
+				break
+			}
+		}
+		exports = append(exports, export)
+	}
+	return exports, nil
+}
+
+// countByVariant counts the answers of each variant of the experiment and their ratings
+func (r *MemRepo) countByVariant(ctx context.Context, experimentID int) ([]VariantCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byVariant := map[string]*VariantCount{}
+	sessions := map[string]map[string]bool{}
+	for _, m := range r.messages {
+		if m.ExperimentID != experimentID || m.Role != RoleAssistant {
+			continue
+		}
+		count, ok := byVariant[m.Variant]
+		if !ok {
+			count = &VariantCount{Variant: m.Variant}
+			byVariant[m.Variant], sessions[m.Variant] = count, map[string]bool{}
+		}
+		sessions[m.Variant][m.SessionID] = true
+		count.Sessions = len(sessions[m.Variant])
+		count.Messages++
+		for _, f := range r.feedback {
+			if f.MessageID != m.ID {
+				continue
+			}
+			if f.Rating == RatingUp {
+				count.ThumbsUp++
+			} else if f.Rating == RatingDown {
+				count.ThumbsDown++
+			}
+		}
+	}
+	counts := []VariantCount{}
+	for _, count := range byVariant {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Variant < counts[j].Variant })
+	return counts, nil
+}
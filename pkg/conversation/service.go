@@ -0,0 +1,166 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	ErrInvalidFeedback = errors.New("invalid feedback")
+)
+
+// maxCommentLength is the longest comment a feedback can have
+const maxCommentLength = 2000
+
+// historyLimit is the most messages of a session loaded after its summary
+const historyLimit = 200
+
+type Service struct {
+	log  *logrus.Logger
+	Repo Repository
+}
+
+// NewService returns a chat message store service object.
+func NewService(log *logrus.Logger, Repo Repository) *Service {
+	return &Service{
+		log:  log,
+		Repo: Repo,
+	}
+}
+
+// Append stores the messages in order, their IDs are set
+func (s *Service) Append(ctx context.Context, messages ...*Message) error {
+	now := time.Now()
+	for _, message := range messages {
+		message.CreatedAt = now
+	}
+	return s.Repo.insertMessages(ctx, messages)
+}
+
+// History returns the summary of the session's older turns, nil when there is none, and the
+// messages after it oldest first. Only the latest historyLimit messages are returned.
+func (s *Service) History(ctx context.Context, userID int, sessionID string) (*Summary, []Message, error) {
+	summary, err := s.Repo.fetchSummary(ctx, userID, sessionID)
+	if err == pg.ErrNoRows {
+		summary, err = nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	afterID := 0
+	if summary != nil {
+		afterID = summary.UpToID
+	}
+	messages, err := s.Repo.retrieveHistory(ctx, userID, sessionID, afterID, historyLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return summary, messages, nil
+}
+
+// Messages calls fn with the messages of the session after afterID and before beforeID, oldest first
+// in pages of historyLimit
+func (s *Service) Messages(ctx context.Context, userID int, sessionID string, afterID, beforeID int, fn func([]Message) error) error {
+	for {
+		messages, err := s.Repo.retrieveMessages(ctx, userID, sessionID, afterID, beforeID, historyLimit)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		if err := fn(messages); err != nil {
+			return err
+		}
+		afterID = messages[len(messages)-1].ID
+	}
+}
+
+// SaveSummary stores the summary of the session, replacing the previous one
+func (s *Service) SaveSummary(ctx context.Context, summary *Summary) error {
+	summary.UpdatedAt = time.Now()
+	if summary.ToolResults == nil {
+		summary.ToolResults = []ToolResult{}
+	}
+	return s.Repo.upsertSummary(ctx, summary)
+}
+
+// CountByVariant counts the sessions and answers of each variant of the experiment
+func (s *Service) CountByVariant(ctx context.Context, experimentID int) ([]VariantCount, error) {
+	return s.Repo.countByVariant(ctx, experimentID)
+}
+
+// SaveFeedback stores the user's rating of an answer of the bot, rating it again replaces the rating.
+// It returns ErrMessageNotFound unless the message is an answer to the user.
+func (s *Service) SaveFeedback(ctx context.Context, userID, messageID int, rating, comment string, reasons []string) (*Feedback, error) {
+	if rating != RatingUp && rating != RatingDown {
+		return nil, fmt.Errorf("%w: rating must be %s or %s", ErrInvalidFeedback, RatingUp, RatingDown)
+	}
+	if len(comment) > maxCommentLength {
+		return nil, fmt.Errorf("%w: the comment is longer than %d characters", ErrInvalidFeedback, maxCommentLength)
+	}
+	tags := []string{}
+	for _, reason := range reasons {
+		reason = strings.ToLower(strings.TrimSpace(reason))
+		if !knownReason(reason) {
+			return nil, fmt.Errorf("%w: unknown reason %q, want one of %s", ErrInvalidFeedback, reason, strings.Join(Reasons, ", "))
+		}
+		tags = append(tags, reason)
+	}
+
+	message, err := s.Repo.fetchMessage(ctx, messageID)
+	if err == pg.ErrNoRows || (err == nil && (message.UserID != userID || message.Role != RoleAssistant)) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	feedback := &Feedback{
+		MessageID:     message.ID,
+		UserID:        userID,
+		Rating:        rating,
+		Comment:       strings.TrimSpace(comment),
+		Reasons:       tags,
+		PromptVersion: message.PromptVersion,
+		ExperimentID:  message.ExperimentID,
+		Variant:       message.Variant,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	return feedback, s.Repo.upsertFeedback(ctx, feedback)
+}
+
+func knownReason(reason string) bool {
+	for _, r := range Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportFeedback calls fn with the feedback given between from and to, in pages of batch
+func (s *Service) ExportFeedback(ctx context.Context, from, to time.Time, batch int, fn func([]FeedbackExport) error) error {
+	afterID := 0
+	for {
+		exports, err := s.Repo.retrieveFeedback(ctx, from, to, afterID, batch)
+		if err != nil {
+			return err
+		}
+		if len(exports) == 0 {
+			return nil
+		}
+		if err := fn(exports); err != nil {
+			return err
+		}
+		afterID = exports[len(exports)-1].ID
+	}
+}
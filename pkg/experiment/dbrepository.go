@@ -0,0 +1,96 @@
+package experiment
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	insertExperiment(context.Context, *Experiment) (bool, error)
+	retrieveExperiments(context.Context) ([]Experiment, error)
+	fetchExperiment(context.Context, int) (*Experiment, error)
+	fetchRunning(context.Context) (*Experiment, error)
+	stopExperiment(context.Context, int, time.Time) error
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+// insertExperiment reports whether the experiment was stored, it is not when its name is
+// taken or another experiment is running
+func (r *PGRepo) insertExperiment(ctx context.Context, experiment *Experiment) (bool, error) {
+	res, err := r.db.ModelContext(ctx, experiment).
+		OnConflict("DO NOTHING").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+func (r *PGRepo) retrieveExperiments(ctx context.Context) ([]Experiment, error) {
+	experiments := []Experiment{}
+	err := r.db.ModelContext(ctx, &experiments).
+		Order("id DESC").
+		Select()
+	return experiments, err
+}
+
+func (r *PGRepo) fetchExperiment(ctx context.Context, id int) (*Experiment, error) {
+	experiment := &Experiment{}
+	err := r.db.ModelContext(ctx, experiment).
+		Where("id = ?", id).
+		Select()
+	return experiment, err
+}
+
+func (r *PGRepo) fetchRunning(ctx context.Context) (*Experiment, error) {
+	experiment := &Experiment{}
+	err := r.db.ModelContext(ctx, experiment).
+		Where("stopped_at IS NULL").
+		Select()
+	return experiment, err
+}
+
+// stopExperiment stops the experiment, it returns pg.ErrNoRows when it is not running
+func (r *PGRepo) stopExperiment(ctx context.Context, id int, at time.Time) error {
+	res, err := r.db.ModelContext(ctx, (*Experiment)(nil)).
+		Set("stopped_at = ?", at).
+		Where("id = ?", id).
+		Where("stopped_at IS NULL").
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
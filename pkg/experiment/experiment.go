@@ -0,0 +1,66 @@
+package experiment
+
+import (
+	"time"
+	"uber_fx_init_folder_structure/pkg/usage"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the prompt and model profile experiments
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+type (
+	// Experiment splits chat sessions between variants, at most one experiment runs at a time
+	Experiment struct {
+		tableName   struct{}  `pg:"experiments,discard_unknown_columns"`
+		ID          int       `json:"id" pg:"id,pk"`
+		Name        string    `json:"name" pg:"name,unique"`
+		Description string    `json:"description,omitempty" pg:"description"`
+		Variants    []Variant `json:"variants" pg:"variants,type:jsonb"`
+		CreatedBy   int       `json:"created_by" pg:"created_by"`
+		StartedAt   time.Time `json:"started_at" pg:"started_at"`
+		// StoppedAt is zero while the experiment runs
+		StoppedAt time.Time `json:"stopped_at,omitempty" pg:"stopped_at"`
+	}
+	// Variant answers the sessions assigned to it with a version of the system prompt and a model profile
+	Variant struct {
+		Name string `json:"name"`
+		// PromptVersion is the version of the system prompt, the active one when 0
+		PromptVersion int `json:"prompt_version"`
+		// Profile is the model profile of the main and followup stages, the configured ones when empty
+		Profile string `json:"profile,omitempty"`
+		// Weight is the share of sessions assigned to the variant, relative to the other variants
+		Weight int `json:"weight"`
+	}
+	// Assignment is the variant of the running experiment a session is assigned to
+	Assignment struct {
+		ExperimentID int
+		Variant      Variant
+	}
+	// Report compares the variants of an experiment
+	Report struct {
+		Experiment *Experiment     `json:"experiment"`
+		Variants   []VariantReport `json:"variants"`
+	}
+	// VariantReport is what the sessions assigned to a variant used and how users rated its answers
+	VariantReport struct {
+		Variant  string `json:"variant"`
+		Sessions int    `json:"sessions"`
+		// Messages counts the answers of the variant
+		Messages   int           `json:"messages"`
+		ThumbsUp   int           `json:"thumbs_up"`
+		ThumbsDown int           `json:"thumbs_down"`
+		Usage      usage.Summary `json:"usage"`
+	}
+)
+
+// Running reports whether the experiment has not been stopped
+func (e *Experiment) Running() bool {
+	return e.StoppedAt.IsZero()
+}
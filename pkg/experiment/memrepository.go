@@ -0,0 +1,77 @@
+package experiment
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// MemRepo keeps experiments in memory, it backs tests that run without postgres
+type MemRepo struct {
+	mu          sync.Mutex
+	experiments []Experiment
+}
+
+// NewMemRepository returns an empty in-memory persistence layer
+func NewMemRepository() Repository {
+	return &MemRepo{}
+}
+
+// insertExperiment reports whether the experiment was stored, it is not when its name is
+// taken or another experiment is running
+func (r *MemRepo) insertExperiment(ctx context.Context, experiment *Experiment) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.experiments {
+		if e.Name == experiment.Name || (e.StoppedAt.IsZero() && experiment.StoppedAt.IsZero()) {
+			return false, nil
+		}
+	}
+	experiment.ID = len(r.experiments) + 1
+	r.experiments = append(r.experiments, *experiment)
+	return true, nil
+}
+
+func (r *MemRepo) retrieveExperiments(ctx context.Context) ([]Experiment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	experiments := []Experiment{}
+	for i := len(r.experiments) - 1; i >= 0; i-- {
+		experiments = append(experiments, r.experiments[i])
+	}
+	return experiments, nil
+}
+
+func (r *MemRepo) fetchExperiment(ctx context.Context, id int) (*Experiment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id <= 0 || id > len(r.experiments) {
+		return &Experiment{}, pg.ErrNoRows
+	}
+	experiment := r.experiments[id-1]
+	return &experiment, nil
+}
+
+func (r *MemRepo) fetchRunning(ctx context.Context) (*Experiment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.experiments {
+		if e.StoppedAt.IsZero() {
+			return &e, nil
+		}
+	}
+	return &Experiment{}, pg.ErrNoRows
+}
+
+// stopExperiment stops the experiment, it returns pg.ErrNoRows when it is not running
+func (r *MemRepo) stopExperiment(ctx context.Context, id int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id <= 0 || id > len(r.experiments) || !r.experiments[id-1].StoppedAt.IsZero() {
+		return pg.ErrNoRows
+	}
+	r.experiments[id-1].StoppedAt = at
+	return nil
+}
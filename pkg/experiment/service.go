@@ -0,0 +1,249 @@
+package experiment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/pkg/cache"
+	"uber_fx_init_folder_structure/pkg/cache/persistence"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/llm"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/pkg/usage"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrExperimentNotFound = errors.New("experiment not found")
+	ErrExperimentRunning  = errors.New("another experiment is running, stop it first")
+	ErrExperimentExists   = errors.New("an experiment with this name exists")
+	ErrInvalidVariants    = errors.New("invalid variants")
+)
+
+const runningKey = "experiment:running"
+
+type Service struct {
+	conf         *viper.Viper
+	log          *logrus.Logger
+	Repo         Repository
+	cache        *cache.Service
+	prompt       *prompt.Service
+	profiles     *llm.Profiles
+	usage        *usage.Service
+	conversation *conversation.Service
+}
+
+// NewService returns an experiment service object.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, cache *cache.Service, prompt *prompt.Service, profiles *llm.Profiles, usage *usage.Service, conversation *conversation.Service) *Service {
+	return &Service{
+		conf:         conf,
+		log:          log,
+		Repo:         Repo,
+		cache:        cache,
+		prompt:       prompt,
+		profiles:     profiles,
+		usage:        usage,
+		conversation: conversation,
+	}
+}
+
+// Experiments returns every experiment, latest first
+func (s *Service) Experiments(ctx context.Context) ([]Experiment, error) {
+	return s.Repo.retrieveExperiments(ctx)
+}
+
+// Start stores the experiment and starts assigning new and ongoing sessions to its variants
+func (s *Service) Start(ctx context.Context, name, description string, variants []Variant, createdBy int) (*Experiment, error) {
+	experiment := &Experiment{
+		Name:        strings.ToLower(strings.TrimSpace(name)),
+		Description: description,
+		Variants:    variants,
+		CreatedBy:   createdBy,
+		StartedAt:   time.Now(),
+	}
+	if err := s.validate(ctx, experiment); err != nil {
+		return nil, err
+	}
+	if _, err := s.Repo.fetchRunning(ctx); err != pg.ErrNoRows {
+		if err == nil {
+			err = ErrExperimentRunning
+		}
+		return nil, err
+	}
+	stored, err := s.Repo.insertExperiment(ctx, experiment)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		// another experiment was started meanwhile unless the name is taken
+		if _, err := s.Repo.fetchRunning(ctx); err == nil {
+			return nil, ErrExperimentRunning
+		}
+		return nil, ErrExperimentExists
+	}
+	s.clearCache()
+	return experiment, nil
+}
+
+func (s *Service) validate(ctx context.Context, experiment *Experiment) error {
+	if experiment.Name == "" {
+		return fmt.Errorf("%w: the experiment needs a name", ErrInvalidVariants)
+	}
+	if len(experiment.Variants) < 2 {
+		return fmt.Errorf("%w: an experiment needs at least two variants", ErrInvalidVariants)
+	}
+	names := map[string]bool{}
+	for i := range experiment.Variants {
+		variant := &experiment.Variants[i]
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" || names[variant.Name] {
+			return fmt.Errorf("%w: variant %d needs a unique name", ErrInvalidVariants, i+1)
+		}
+		names[variant.Name] = true
+		if variant.Weight == 0 {
+			variant.Weight = 1
+		}
+		if variant.Weight < 0 {
+			return fmt.Errorf("%w: variant %s has a negative weight", ErrInvalidVariants, variant.Name)
+		}
+		if variant.Profile != "" {
+			// the variant's profile answers the main and followup stages, the followup must not force a tool call
+			for _, stage := range []string{llm.StageMain, llm.StageFollowUp} {
+				if err := s.profiles.Check(stage, variant.Profile); err != nil {
+					return fmt.Errorf("%w: variant %s: %v", ErrInvalidVariants, variant.Name, err)
+				}
+			}
+		}
+		if variant.PromptVersion != 0 {
+			_, err := s.prompt.Version(ctx, prompt.System, variant.PromptVersion)
+			if err == prompt.ErrPromptNotFound {
+				return fmt.Errorf("%w: variant %s: prompt version %d not found", ErrInvalidVariants, variant.Name, variant.PromptVersion)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stop stops the experiment, sessions are answered by the active prompt and the configured profiles again
+func (s *Service) Stop(ctx context.Context, id int) error {
+	err := s.Repo.stopExperiment(ctx, id, time.Now())
+	if err == pg.ErrNoRows {
+		return ErrExperimentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.clearCache()
+	return nil
+}
+
+func (s *Service) clearCache() {
+	if err := s.cache.Delete(runningKey); err != nil && err != persistence.ErrCacheMiss {
+		s.log.Error("failed to clear the cached experiment: " + err.Error())
+	}
+}
+
+// Assign returns the variant of the running experiment the session is assigned to, nil when no
+// experiment runs. A session keeps its variant for as long as the experiment runs.
+func (s *Service) Assign(ctx context.Context, sessionID string) (*Assignment, error) {
+	experiment, err := s.running(ctx)
+	if err != nil || experiment == nil {
+		return nil, err
+	}
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.Weight
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	sum := sha256.Sum256([]byte(strconv.Itoa(experiment.ID) + ":" + sessionID))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	for _, variant := range experiment.Variants {
+		if bucket < variant.Weight {
+			return &Assignment{ExperimentID: experiment.ID, Variant: variant}, nil
+		}
+		bucket -= variant.Weight
+	}
+	return nil, nil
+}
+
+// running returns the running experiment, nil when none runs. It is cached for llm_prompt_cache_ttl.
+func (s *Service) running(ctx context.Context) (*Experiment, error) {
+	var cached string
+	if err := s.cache.Get(runningKey, &cached); err == nil {
+		var experiment *Experiment
+		if err := json.Unmarshal([]byte(cached), &experiment); err == nil {
+			return experiment, nil
+		}
+	}
+
+	experiment, err := s.Repo.fetchRunning(ctx)
+	if err == pg.ErrNoRows {
+		experiment, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(experiment)
+	if err == nil {
+		err = s.cache.Set(runningKey, string(b), s.conf.GetDuration(utils.LLMPromptCacheTTL))
+	}
+	if err != nil {
+		s.log.Error("failed to cache the running experiment: " + err.Error())
+	}
+	return experiment, nil
+}
+
+// Report returns the sessions, answers, ratings and token usage of each variant of the experiment
+func (s *Service) Report(ctx context.Context, id int) (*Report, error) {
+	experiment, err := s.Repo.fetchExperiment(ctx, id)
+	if err == pg.ErrNoRows {
+		return nil, ErrExperimentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.conversation.CountByVariant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.usage.ExperimentReport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Experiment: experiment, Variants: []VariantReport{}}
+	variants := map[string]*VariantReport{}
+	for _, variant := range experiment.Variants {
+		report.Variants = append(report.Variants, VariantReport{Variant: variant.Name})
+	}
+	for i := range report.Variants {
+		variants[report.Variants[i].Variant] = &report.Variants[i]
+	}
+	for _, count := range counts {
+		if v, ok := variants[count.Variant]; ok {
+			v.Sessions, v.Messages = count.Sessions, count.Messages
+			v.ThumbsUp, v.ThumbsDown = count.ThumbsUp, count.ThumbsDown
+		}
+	}
+	for _, summary := range summaries {
+		if v, ok := variants[summary.Key]; ok {
+			v.Usage = summary
+		}
+	}
+	return report, nil
+}
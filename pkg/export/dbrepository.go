@@ -0,0 +1,79 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	createJob(context.Context, *Job) error
+	fetchJob(context.Context, int) (*Job, error)
+	updateJob(context.Context, *Job) error
+	updateProgress(context.Context, int, int) error
+	retrieveExpiredJobs(context.Context, time.Time) ([]Job, error)
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+func (r *PGRepo) createJob(ctx context.Context, job *Job) error {
+	_, err := r.db.ModelContext(ctx, job).Insert()
+	return err
+}
+
+func (r *PGRepo) fetchJob(ctx context.Context, id int) (*Job, error) {
+	job := &Job{}
+	err := r.db.ModelContext(ctx, job).Where("id = ?", id).Select()
+	return job, err
+}
+
+func (r *PGRepo) updateJob(ctx context.Context, job *Job) error {
+	job.UpdatedAt = time.Now()
+	_, err := r.db.ModelContext(ctx, job).WherePK().Update()
+	return err
+}
+
+func (r *PGRepo) updateProgress(ctx context.Context, id, done int) error {
+	_, err := r.db.ModelContext(ctx, (*Job)(nil)).
+		Set("done = ?", done).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Update()
+	return err
+}
+
+func (r *PGRepo) retrieveExpiredJobs(ctx context.Context, before time.Time) ([]Job, error) {
+	jobs := []Job{}
+	err := r.db.ModelContext(ctx, &jobs).
+		Where("status = ?", StatusDone).
+		Where("expires_at < ?", before).
+		Select()
+	return jobs, err
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// JobsModule provides the photo export service and registers its jobs, without the chat
+var JobsModule = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+	fx.Invoke(
+		RegisterJobs,
+	),
+)
+
+// Module provides the photo export service and registers its chat tool and jobs
+var Module = fx.Options(
+	JobsModule,
+	fx.Invoke(
+		RegisterTools,
+	),
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+	StatusExpired = "expired"
+)
+
+type (
+	// Job is a ZIP export of a user's photos
+	Job struct {
+		tableName struct{}  `pg:"export_jobs,discard_unknown_columns"`
+		ID        int       `json:"id" pg:"id,pk"`
+		UserID    int       `json:"-" pg:"user_id"`
+		Status    string    `json:"status" pg:"status"`
+		Album     string    `json:"album,omitempty" pg:"album"`
+		From      time.Time `json:"from,omitempty" pg:"from_date"`
+		To        time.Time `json:"to,omitempty" pg:"to_date"`
+		Total     int       `json:"total" pg:"total,use_zero"`
+		Done      int       `json:"done" pg:"done,use_zero"`
+		ObjectKey string    `json:"-" pg:"object_key"`
+		Error     string    `json:"error,omitempty" pg:"error"`
+		ExpiresAt time.Time `json:"expires_at,omitempty" pg:"expires_at"`
+		CreatedAt time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt time.Time `json:"updated_at" pg:"updated_at"`
+
+		// DownloadURL is a presigned link to the archive, set once the job is done
+		DownloadURL string `json:"download_url,omitempty" pg:"-"`
+	}
+	// Request selects the photos to export, zero values match everything
+	Request struct {
+		Album string
+		From  time.Time
+		// To is exclusive
+		To time.Time
+	}
+	// Manifest is written as manifest.json at the end of every archive
+	Manifest struct {
+		ExportID   int             `json:"export_id"`
+		Username   string          `json:"username"`
+		Album      string          `json:"album,omitempty"`
+		From       time.Time       `json:"from,omitempty"`
+		To         time.Time       `json:"to,omitempty"`
+		CreatedAt  time.Time       `json:"created_at"`
+		Photos     []ManifestEntry `json:"photos"`
+		Missing    []ManifestEntry `json:"missing,omitempty"`
+		TotalBytes int64           `json:"total_bytes"`
+	}
+	ManifestEntry struct {
+		ID          int       `json:"id"`
+		File        string    `json:"file,omitempty"`
+		Url         string    `json:"url"`
+		ContentType string    `json:"content_type"`
+		Album       string    `json:"album,omitempty"`
+		Tags        []string  `json:"tags,omitempty"`
+		Size        int64     `json:"size,omitempty"`
+		CreatedAt   time.Time `json:"created_at"`
+		Error       string    `json:"error,omitempty"`
+	}
+)
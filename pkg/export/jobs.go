@@ -0,0 +1,47 @@
+package export
+
+import (
+	"context"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/utils"
+)
+
+// background job types of the export package
+const (
+	JobRun     = "export.run"
+	JobCleanup = "export.cleanup"
+)
+
+type runJob struct {
+	ExportID int    `json:"export_id"`
+	Username string `json:"username"`
+}
+
+// RegisterJobs registers the export and expired export cleanup jobs on the queue
+func RegisterJobs(q *queue.Service, s *Service) {
+	q.Register(JobRun, s.runJob)
+	q.Register(JobCleanup, s.cleanupJob)
+	q.Schedule(JobCleanup, s.conf.GetDuration(utils.ExportCleanupInterval))
+}
+
+func (s *Service) runJob(ctx context.Context, job queue.Job) error {
+	payload := runJob{}
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+	err := s.Run(ctx, payload.ExportID, payload.Username)
+	if err != nil && job.LastAttempt() {
+		if fErr := s.Fail(ctx, payload.ExportID, payload.Username, err); fErr != nil {
+			s.log.WithField("export_id", payload.ExportID).Error(fErr.Error())
+		}
+	}
+	return err
+}
+
+func (s *Service) cleanupJob(ctx context.Context, job queue.Job) error {
+	purged, err := s.PurgeExpired(ctx)
+	if purged > 0 {
+		s.log.WithField("purged", purged).Info("expired exports removed")
+	}
+	return err
+}
@@ -0,0 +1,328 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/pkg/notify"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/pkg/user"
+	"uber_fx_init_folder_structure/utils"
+	"uber_fx_init_folder_structure/utils/types"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	exportPageSize = 100
+	// progressEvery is how many photos are archived between two progress notifications
+	progressEvery = 10
+	// maxLinkExpiry is the longest lifetime of an S3 presigned URL
+	maxLinkExpiry = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidDate = errors.New("invalid date, use YYYY-MM-DD")
+	ErrExpired     = errors.New("the export has expired, please start a new one")
+)
+
+type Service struct {
+	conf    *viper.Viper
+	log     *logrus.Logger
+	Repo    Repository
+	user    *user.PhotoService
+	storage *storage.Service
+	notify  *notify.Service
+	queue   *queue.Service
+}
+
+// NewService returns an export service object.
+// It fails when export_link_expiry is longer than a download link can be signed for.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, user *user.PhotoService, storage *storage.Service, notify *notify.Service, queue *queue.Service) (*Service, error) {
+	if expiry := conf.GetDuration(utils.ExportLinkExpiry); expiry <= 0 || expiry > maxLinkExpiry {
+		return nil, fmt.Errorf("export_link_expiry must be between 0 and %s, got %q", maxLinkExpiry, conf.GetString(utils.ExportLinkExpiry))
+	}
+	return &Service{
+		conf:    conf,
+		log:     log,
+		Repo:    Repo,
+		user:    user,
+		storage: storage,
+		notify:  notify,
+		queue:   queue,
+	}, nil
+}
+
+// ParseDateRange parses optional YYYY-MM-DD dates into a Request range.
+// `to` is inclusive, the returned upper bound is the start of the following day.
+func ParseDateRange(from, to string) (fromDate, toDate time.Time, err error) {
+	if from != "" {
+		if fromDate, err = time.Parse("2006-01-02", from); err != nil {
+			return fromDate, toDate, ErrInvalidDate
+		}
+	}
+	if to != "" {
+		if toDate, err = time.Parse("2006-01-02", to); err != nil {
+			return fromDate, toDate, ErrInvalidDate
+		}
+		toDate = toDate.AddDate(0, 0, 1)
+	}
+	return fromDate, toDate, nil
+}
+
+// Start creates an export job for the user and enqueues it for a worker.
+// Progress is reported to the user's websocket.
+func (s *Service) Start(ctx context.Context, userDetails *user.User, req Request) (*Job, error) {
+	now := time.Now()
+	job := &Job{
+		UserID:    userDetails.ID,
+		Status:    StatusPending,
+		Album:     req.Album,
+		From:      req.From,
+		To:        req.To,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.Repo.createJob(ctx, job); err != nil {
+		return nil, err
+	}
+	_, err := s.queue.Enqueue(ctx, JobRun, runJob{ExportID: job.ID, Username: userDetails.Username})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// FetchJob returns an export job the principal may read, with a download link once it is done.
+// It returns ErrExpired once the download link has expired, whether or not the archive was deleted yet.
+func (s *Service) FetchJob(ctx context.Context, principal *user.User, id int) (*Job, error) {
+	job, err := s.Repo.fetchJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// exports contain every selected photo, they are never shared
+	if job.UserID != principal.ID && !s.user.HasPermission(ctx, principal, types.PHOTOMANAGEMENT) {
+		return nil, user.ErrForbidden
+	}
+	if job.Status == StatusExpired || (job.Status == StatusDone && !time.Now().Before(job.ExpiresAt)) {
+		return nil, ErrExpired
+	}
+	if job.Status == StatusDone {
+		job.DownloadURL, err = s.storage.PresignGet(job.ObjectKey, time.Until(job.ExpiresAt))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// Run archives the photos selected by the job into a ZIP object and
+// stores the result on the job. Running a job that is already done does nothing.
+func (s *Service) Run(ctx context.Context, id int, username string) error {
+	job, err := s.Repo.fetchJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status == StatusDone || job.Status == StatusExpired {
+		return nil
+	}
+
+	photos, err := s.selectPhotos(ctx, job)
+	if err != nil {
+		return err
+	}
+	job.Status = StatusRunning
+	job.Total = len(photos)
+	if err = s.Repo.updateJob(ctx, job); err != nil {
+		return err
+	}
+	s.notify.Publish(username, fmt.Sprintf("export #%d started: %d photos", job.ID, job.Total))
+
+	key := fmt.Sprintf("exports/%d/%s.zip", job.UserID, uuid.New())
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.writeArchive(ctx, pw, job, username, photos))
+	}()
+	if err = s.storage.Upload(ctx, key, pr, "application/zip"); err != nil {
+		// unblock the archive writer
+		pr.CloseWithError(err)
+		return err
+	}
+
+	job.Status = StatusDone
+	job.Done = job.Total
+	job.ObjectKey = key
+	job.ExpiresAt = time.Now().Add(s.linkExpiry())
+	if err = s.Repo.updateJob(ctx, job); err != nil {
+		return err
+	}
+	link, err := s.storage.PresignGet(key, s.linkExpiry())
+	if err != nil {
+		return err
+	}
+	s.notify.Publish(username, fmt.Sprintf("export #%d is ready, download it before %s: %s",
+		job.ID, job.ExpiresAt.Format(time.RFC1123), link))
+	return nil
+}
+
+// Fail marks an export as failed and tells the user
+func (s *Service) Fail(ctx context.Context, id int, username string, cause error) error {
+	job, err := s.Repo.fetchJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	job.Status = StatusFailed
+	job.Error = cause.Error()
+	if err := s.Repo.updateJob(ctx, job); err != nil {
+		return err
+	}
+	s.notify.Publish(username, fmt.Sprintf("export #%d failed, please try again", job.ID))
+	return nil
+}
+
+// selectPhotos pages through all active photos matching the job
+func (s *Service) selectPhotos(ctx context.Context, job *Job) ([]user.UserImages, error) {
+	photos := []user.UserImages{}
+	filter := user.PhotoFilter{
+		Album: job.Album,
+		From:  job.From,
+		To:    job.To,
+		Sort:  "created_at",
+		Limit: exportPageSize,
+	}
+	for {
+		page, err := s.user.ListPhotos(ctx, job.UserID, filter)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, page.Photos...)
+		if !page.HasMore {
+			return photos, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// writeArchive streams every photo and the manifest into a ZIP written to w
+func (s *Service) writeArchive(ctx context.Context, w io.Writer, job *Job, username string, photos []user.UserImages) error {
+	zw := zip.NewWriter(w)
+	manifest := Manifest{
+		ExportID:  job.ID,
+		Username:  username,
+		Album:     job.Album,
+		From:      job.From,
+		To:        job.To,
+		CreatedAt: time.Now(),
+		Photos:    []ManifestEntry{},
+	}
+
+	for i, photo := range photos {
+		entry := ManifestEntry{
+			ID:          photo.ID,
+			Url:         photo.Url,
+			ContentType: photo.ContentType,
+			Album:       photo.Album,
+			Tags:        photo.Tags,
+			CreatedAt:   photo.CreatedAt,
+		}
+		size, err := s.archivePhoto(ctx, zw, photo, &entry)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// a single unreadable object should not fail the whole export
+			entry.File = ""
+			entry.Error = err.Error()
+			manifest.Missing = append(manifest.Missing, entry)
+		} else {
+			entry.Size = size
+			manifest.TotalBytes += size
+			manifest.Photos = append(manifest.Photos, entry)
+		}
+
+		done := i + 1
+		if err := s.Repo.updateProgress(ctx, job.ID, done); err != nil {
+			s.log.WithField("export_id", job.ID).Warn(err.Error())
+		}
+		if done%progressEvery == 0 && done < len(photos) {
+			s.notify.Publish(username, fmt.Sprintf("export #%d: %d/%d photos", job.ID, done, len(photos)))
+		}
+	}
+
+	mw, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// archivePhoto reads the photo before adding it to the archive, so that a read failing midway
+// leaves no truncated file in the archive and the photo is listed as missing instead
+func (s *Service) archivePhoto(ctx context.Context, zw *zip.Writer, photo user.UserImages, entry *ManifestEntry) (int64, error) {
+	key := s.storage.KeyFromURL(photo.Url)
+	body, err := s.storage.Open(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, body); err != nil {
+		return 0, err
+	}
+
+	dir := photo.Album
+	if dir == "" {
+		dir = "unsorted"
+	}
+	entry.File = path.Join("photos", sanitizeName(dir), fmt.Sprintf("%d-%s", photo.ID, sanitizeName(path.Base(key))))
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     entry.File,
+		Method:   zip.Store, // photos are already compressed
+		Modified: photo.CreatedAt,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(fw, buf)
+}
+
+// PurgeExpired deletes the archives of exports whose download link has expired
+func (s *Service) PurgeExpired(ctx context.Context) (int, error) {
+	jobs, err := s.Repo.retrieveExpiredJobs(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for i, job := range jobs {
+		if err := s.storage.Delete(ctx, job.ObjectKey); err != nil {
+			return i, err
+		}
+		job.Status = StatusExpired
+		if err := s.Repo.updateJob(ctx, &job); err != nil {
+			return i, err
+		}
+	}
+	return len(jobs), nil
+}
+
+func (s *Service) linkExpiry() time.Duration {
+	return s.conf.GetDuration(utils.ExportLinkExpiry)
+}
+
+func sanitizeName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(name)
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/user"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+type exportPhotosArgs struct {
+	Album string `json:"album"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// RegisterTools adds the export chat tools to the bot
+func RegisterTools(userService *user.Service, s *Service) {
+	userService.RegisterTool(openai.FunctionDefinition{
+		Name:        "ExportPhotos",
+		Description: "starts a ZIP export of all the user's photos, optionally only one album or a date range. use it when the user asks to send or download all their photos",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"album": {Type: jsonschema.String, Description: "only export this album e.g., goa"},
+				"from":  {Type: jsonschema.String, Description: "only export photos uploaded on or after this date, YYYY-MM-DD"},
+				"to":    {Type: jsonschema.String, Description: "only export photos uploaded on or before this date, YYYY-MM-DD"},
+			},
+		},
+	}, apikey.ScopeExports, s.exportPhotosTool)
+}
+
+func (s *Service) exportPhotosTool(ctx context.Context, principal *user.User, raw json.RawMessage) (string, error) {
+	args := exportPhotosArgs{}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	from, to, err := ParseDateRange(args.From, args.To)
+	if err != nil {
+		return "dates must be in YYYY-MM-DD format ask the user again", nil
+	}
+	job, err := s.Start(ctx, principal, Request{Album: args.Album, From: from, To: to})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("export #%d started, tell the user progress and the download link will show up in this chat", job.ID), nil
+}
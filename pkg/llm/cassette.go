@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cassette modes
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// ErrCassetteNotFound is returned in replay mode for requests that were never recorded
+var ErrCassetteNotFound = errors.New("no cassette recorded for the request")
+
+// request headers stored in cassettes, every other header, credentials included, is left out
+var cassetteRequestHeaders = []string{"Content-Type", "OpenAI-Beta"}
+
+// response headers stored in cassettes
+var cassetteResponseHeaders = []string{"Content-Type", "Retry-After"}
+
+type (
+	// Cassette is one recorded request with its response
+	Cassette struct {
+		Request  CassetteRequest  `json:"request"`
+		Response CassetteResponse `json:"response"`
+	}
+	CassetteRequest struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body,omitempty"`
+	}
+	CassetteResponse struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body,omitempty"`
+	}
+)
+
+// cassetteTransport records the traffic of the chat client to dir or replays it from there.
+// Requests are matched by their method, path and normalized body.
+type cassetteTransport struct {
+	log  *logrus.Logger
+	mode string
+	dir  string
+	next http.RoundTripper
+	mu   sync.Mutex
+}
+
+func newCassetteTransport(log *logrus.Logger, mode, dir string) (*cassetteTransport, error) {
+	if mode != CassetteRecord && mode != CassetteReplay {
+		return nil, fmt.Errorf("unknown llm cassette mode %q, want record or replay", mode)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &cassetteTransport{log: log, mode: mode, dir: dir, next: http.DefaultTransport}, nil
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := normalizeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	name := cassetteName(recorded)
+	path := filepath.Join(t.dir, name+".json")
+
+	if t.mode == CassetteReplay {
+		raw, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, t.unmatched(name, recorded)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cassette := Cassette{}
+		if err := json.Unmarshal(raw, &cassette); err != nil {
+			return nil, fmt.Errorf("cassette %s: %w", path, err)
+		}
+		return cassette.Response.httpResponse(req), nil
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	cassette := Cassette{
+		Request: recorded,
+		Response: CassetteResponse{
+			Status:  res.StatusCode,
+			Headers: pickHeaders(res.Header, cassetteResponseHeaders),
+			Body:    normalizeJSON(body),
+		},
+	}
+	if err := t.write(path, cassette); err != nil {
+		t.log.WithField("cassette", path).Error("failed to record cassette: " + err.Error())
+	}
+	return res, nil
+}
+
+// unmatched stores the request under unmatched/ so that it can be diffed with the recorded cassettes
+func (t *cassetteTransport) unmatched(name string, recorded CassetteRequest) error {
+	path := filepath.Join(t.dir, "unmatched", name+".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+		t.write(path, Cassette{Request: recorded})
+	}
+	t.log.WithField("cassette", path).Error("llm replay: no cassette for " + recorded.Method + " " + recorded.URL)
+	return fmt.Errorf("%w: %s %s, the request was written to %s", ErrCassetteNotFound, recorded.Method, recorded.URL, path)
+}
+
+func (t *cassetteTransport) write(path string, cassette Cassette) error {
+	raw, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return os.WriteFile(path, append(raw, '\n'), 0o644)
+}
+
+// normalizeRequest keeps what identifies the request, the host is dropped so that
+// cassettes replay against any base URL
+func normalizeRequest(req *http.Request) (CassetteRequest, error) {
+	recorded := CassetteRequest{
+		Method:  req.Method,
+		URL:     req.URL.RequestURI(),
+		Headers: pickHeaders(req.Header, cassetteRequestHeaders),
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	recorded.Body = normalizeJSON(body)
+	return recorded, nil
+}
+
+// normalizeJSON sorts object keys and indents the body, bodies that are not JSON are kept as a string
+func normalizeJSON(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err == nil {
+		if normalized, err := json.MarshalIndent(v, "", "  "); err == nil {
+			return normalized
+		}
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+// cassetteName is the hash of the normalized request
+func cassetteName(recorded CassetteRequest) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", recorded.Method, recorded.URL)
+	h.Write(recorded.Body)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func pickHeaders(header http.Header, names []string) map[string]string {
+	picked := map[string]string{}
+	for _, name := range names {
+		if v := header.Get(name); v != "" {
+			picked[name] = v
+		}
+	}
+	return picked
+}
+
+func (r CassetteResponse) httpResponse(req *http.Request) *http.Response {
+	body := []byte(r.Body)
+	var s string
+	if json.Unmarshal(r.Body, &s) == nil {
+		// bodies that were not JSON are stored as a string
+		body = []byte(s)
+	}
+	header := http.Header{}
+	for name, v := range r.Headers {
+		header.Set(name, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
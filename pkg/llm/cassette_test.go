@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// greeting is the request recorded in testdata/cassettes
+func greeting(message string) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are Alexia, a helpful AI assistant"},
+			{Role: openai.ChatMessageRoleUser, Content: message},
+		},
+	}
+}
+
+// replayModel returns a model replaying a copy of testdata/cassettes, so that unmatched
+// requests are not written into testdata. The base URL is never reached.
+func replayModel(t *testing.T) (ChatModel, string) {
+	t.Helper()
+	dir := t.TempDir()
+	cassettes, err := filepath.Glob(filepath.Join("testdata", "cassettes", "*.json"))
+	if err != nil || len(cassettes) == 0 {
+		t.Fatalf("no cassettes in testdata: %v", err)
+	}
+	for _, path := range cassettes {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(path)), raw, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	conf := viper.New()
+	conf.Set(utils.LLMCassetteMode, CassetteReplay)
+	conf.Set(utils.LLMCassetteDir, dir)
+	model, err := newDriver(NewChatModelIn{Conf: conf, Log: log}, driverSettings{
+		driver:  DriverOpenAI,
+		model:   openai.GPT3Dot5Turbo,
+		key:     "sk-replay",
+		baseURL: "http://127.0.0.1:1/v1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model, dir
+}
+
+func TestCassetteReplay(t *testing.T) {
+	model, _ := replayModel(t)
+	resp, err := model.CreateChatCompletion(context.Background(), greeting("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Choices[0].Message.Content, "Hi! Would you like to upload photos or see them?"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+	if resp.Usage.TotalTokens != 31 {
+		t.Errorf("total tokens = %d, want 31", resp.Usage.TotalTokens)
+	}
+}
+
+func TestCassetteReplayUnmatched(t *testing.T) {
+	model, dir := replayModel(t)
+	_, err := model.CreateChatCompletion(context.Background(), greeting("good bye"))
+	if !errors.Is(err, ErrCassetteNotFound) {
+		t.Fatalf("err = %v, want ErrCassetteNotFound", err)
+	}
+	unmatched, _ := filepath.Glob(filepath.Join(dir, "unmatched", "*.json"))
+	if len(unmatched) != 1 {
+		t.Errorf("wrote %d unmatched requests, want 1", len(unmatched))
+	}
+}
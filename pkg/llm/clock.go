@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"fmt"
+	"time"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/spf13/viper"
+)
+
+// cassetteTime is the time prompts are rendered with while recording or replaying cassettes
+// without llm_clock, so that a cassette matches whenever it is replayed
+var cassetteTime = time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+// Clock returns the time the prompts of a message are rendered with
+type Clock func() time.Time
+
+// NewClock returns the wall clock, or a clock pinned to llm_clock when it is set. Recording or replaying
+// cassettes pins an unset llm_clock too, the rendered time is part of every recorded request.
+func NewClock(conf *viper.Viper) (Clock, error) {
+	pinned := conf.GetString(utils.LLMClock)
+	if pinned == "" {
+		if conf.GetString(utils.LLMCassetteMode) == "" {
+			return func() time.Time { return time.Now().UTC() }, nil
+		}
+		return func() time.Time { return cassetteTime }, nil
+	}
+	t, err := time.Parse(time.RFC3339, pinned)
+	if err != nil {
+		return nil, fmt.Errorf("llm_clock must be an RFC 3339 time e.g. 2024-05-01T09:30:00Z: %w", err)
+	}
+	t = t.UTC()
+	return func() time.Time { return t }, nil
+}
@@ -0,0 +1,33 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/fx"
+)
+
+// Module provides the chat model picked by llm_driver, the model profiles and the clock prompts are rendered with
+var Module = fx.Options(
+	fx.Provide(
+		NewChatModel,
+		NewProfiles,
+		NewClock,
+	),
+)
+
+// drivers of the chat model
+const (
+	DriverOpenAI = "openai"
+	DriverAzure  = "azure"
+	// DriverLocal talks to a self-hosted server with an OpenAI compatible API, e.g. Ollama or vLLM
+	DriverLocal = "local"
+)
+
+// ChatModel completes chat dialogues. Requests and responses use the OpenAI types whatever the driver.
+type ChatModel interface {
+	// CreateChatCompletion answers the dialogue, an empty request model is the configured model
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	// Model returns the configured model name
+	Model() string
+}
@@ -0,0 +1,205 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/spf13/viper"
+)
+
+// pipeline stages, each picks a profile
+const (
+	// StageMain answers the user's message
+	StageMain = "main"
+	// StageFollowUp answers with the results of the tools the model called
+	StageFollowUp = "followup"
+	// StageSummary summarizes conversations
+	StageSummary = "summary"
+)
+
+// Stages lists every stage
+var Stages = []string{StageMain, StageFollowUp, StageSummary}
+
+type (
+	// Profile holds the parameters of a completion request, unset parameters are the model's defaults
+	Profile struct {
+		// Model overrides the driver's model
+		Model       string   `json:"model,omitempty"`
+		Temperature *float32 `json:"temperature,omitempty"`
+		TopP        *float32 `json:"top_p,omitempty"`
+		MaxTokens   int      `json:"max_tokens,omitempty"`
+		Seed        *int     `json:"seed,omitempty"`
+		Stop        []string `json:"stop,omitempty"`
+		// ToolChoice is auto, none, required or the name of the tool to call
+		ToolChoice string `json:"tool_choice,omitempty"`
+	}
+	// Profiles are named profiles, the profile of each stage and the overrides of users with a role.
+	// Roles stand in for tenants, e.g. {"premium": {"main": "large"}}.
+	Profiles struct {
+		Profiles map[string]Profile           `json:"profiles"`
+		Stages   map[string]string            `json:"stages"`
+		Roles    map[string]map[string]string `json:"roles,omitempty"`
+	}
+)
+
+// defaultProfiles are used when llm_profiles is not set
+var defaultProfiles = Profiles{
+	Profiles: map[string]Profile{
+		"main":     {Temperature: float32Ptr(2), TopP: float32Ptr(0.01)},
+		"followup": {},
+		"summary":  {Temperature: float32Ptr(0), MaxTokens: 300},
+	},
+	Stages: map[string]string{
+		StageMain:     "main",
+		StageFollowUp: "followup",
+		StageSummary:  "summary",
+	},
+}
+
+// NewProfiles reads the llm_profiles file and validates it, the built-in profiles are used when it is not set
+func NewProfiles(conf *viper.Viper) (*Profiles, error) {
+	path := conf.GetString(utils.LLMProfiles)
+	if path == "" {
+		profiles := defaultProfiles
+		return &profiles, profiles.validate()
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := &Profiles{}
+	if err := json.Unmarshal(raw, profiles); err != nil {
+		return nil, fmt.Errorf("llm profiles %s: %w", path, err)
+	}
+	if err := profiles.validate(); err != nil {
+		return nil, fmt.Errorf("llm profiles %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// For returns the profile of the stage for a user with the roles, the first role
+// in alphabetical order with an override for the stage wins
+func (p *Profiles) For(stage string, roles []string) Profile {
+	sorted := append([]string{}, roles...)
+	sort.Strings(sorted)
+	for _, role := range sorted {
+		if name, ok := p.Roles[role][stage]; ok {
+			return p.Profiles[name]
+		}
+	}
+	return p.Profiles[p.Stages[stage]]
+}
+
+// Named returns the profile with the name
+func (p *Profiles) Named(name string) (Profile, bool) {
+	profile, ok := p.Profiles[name]
+	return profile, ok
+}
+
+// HasRoleOverrides reports whether the profile depends on the user's roles
+func (p *Profiles) HasRoleOverrides() bool {
+	return len(p.Roles) > 0
+}
+
+func (p *Profiles) validate() error {
+	for name, profile := range p.Profiles {
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	for _, stage := range Stages {
+		if _, ok := p.Stages[stage]; !ok {
+			return fmt.Errorf("stage %s has no profile", stage)
+		}
+	}
+	for stage, name := range p.Stages {
+		if err := p.Check(stage, name); err != nil {
+			return err
+		}
+	}
+	for role, stages := range p.Roles {
+		for stage, name := range stages {
+			if err := p.Check(stage, name); err != nil {
+				return fmt.Errorf("role %s: %w", role, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Check returns an error unless the named profile exists and may answer the stage
+func (p *Profiles) Check(stage, name string) error {
+	known := false
+	for _, s := range Stages {
+		known = known || s == stage
+	}
+	if !known {
+		return fmt.Errorf("unknown stage %q, want main, followup or summary", stage)
+	}
+	profile, ok := p.Profiles[name]
+	if !ok {
+		return fmt.Errorf("stage %s: unknown profile %q", stage, name)
+	}
+	// only the main stage may force a tool call, a forced call in the followup is answered with
+	// another followup and the summary is not offered tools
+	if stage != StageMain && profile.ToolChoice != "" && profile.ToolChoice != "auto" && profile.ToolChoice != "none" {
+		return fmt.Errorf("stage %s: profile %s forces the tool choice %q, want auto or none", stage, name, profile.ToolChoice)
+	}
+	return nil
+}
+
+func (p Profile) validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature %v is not between 0 and 2", *p.Temperature)
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p %v is not above 0 and at most 1", *p.TopP)
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens %d is negative", p.MaxTokens)
+	}
+	if len(p.Stop) > 4 {
+		return fmt.Errorf("%d stop sequences, at most 4 are allowed", len(p.Stop))
+	}
+	return nil
+}
+
+// Apply sets the profile's parameters on the request
+func (p Profile) Apply(req *openai.ChatCompletionRequest) {
+	req.Model = p.Model
+	if p.Temperature != nil {
+		req.Temperature = *p.Temperature
+		if req.Temperature == 0 {
+			// a zero temperature is omitted from the request, which the API takes for 1
+			req.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if p.TopP != nil {
+		req.TopP = *p.TopP
+	}
+	req.MaxTokens = p.MaxTokens
+	req.Seed = p.Seed
+	req.Stop = p.Stop
+	// the API refuses a tool choice without tools
+	if p.ToolChoice != "" && len(req.Tools) > 0 {
+		switch p.ToolChoice {
+		case "auto", "none", "required":
+			req.ToolChoice = p.ToolChoice
+		default:
+			if !offered(req.Tools, p.ToolChoice) {
+				// the user's roles do not allow the tool
+				break
+			}
+			req.ToolChoice = openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: p.ToolChoice}}
+		}
+	}
+}
+
+func float32Ptr(f float32) *float32 {
+	return &f
+}
@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	// ErrUnavailable is returned when neither the model nor the fallback could answer
+	ErrUnavailable = errors.New("chat model unavailable")
+	// ErrCircuitOpen is returned without calling a model that failed repeatedly
+	ErrCircuitOpen = errors.New("circuit breaker open")
+)
+
+// resilientModel times calls out, retries transient failures with exponential backoff,
+// stops calling a failing model for a while and hands its requests to the fallback
+type resilientModel struct {
+	log        *logrus.Logger
+	primary    *guardedModel
+	fallback   *guardedModel
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// guardedModel is a model with its circuit breaker
+type guardedModel struct {
+	ChatModel
+	breaker *breaker
+}
+
+func newResilientModel(conf *viper.Viper, log *logrus.Logger, primary, fallback ChatModel) *resilientModel {
+	guard := func(model ChatModel) *guardedModel {
+		if model == nil {
+			return nil
+		}
+		return &guardedModel{ChatModel: model, breaker: &breaker{
+			threshold: conf.GetInt(utils.LLMBreakerFailures),
+			cooldown:  conf.GetDuration(utils.LLMBreakerCooldown),
+		}}
+	}
+	return &resilientModel{
+		log:        log,
+		primary:    guard(primary),
+		fallback:   guard(fallback),
+		timeout:    conf.GetDuration(utils.LLMTimeout),
+		retries:    conf.GetInt(utils.LLMMaxRetries),
+		backoff:    conf.GetDuration(utils.LLMRetryBackoff),
+		maxBackoff: conf.GetDuration(utils.LLMMaxRetryWait),
+	}
+}
+
+func (m *resilientModel) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := m.call(ctx, m.primary, req)
+	if err == nil || !unavailable(err) || ctx.Err() != nil {
+		return resp, err
+	}
+	if m.fallback != nil {
+		m.log.WithField("model", m.primary.Model()).Warn("llm: falling back to " + m.fallback.Model() + ": " + err.Error())
+		// the fallback answers with its own model
+		req.Model = ""
+		resp, err = m.call(ctx, m.fallback, req)
+		if err == nil || !unavailable(err) {
+			return resp, err
+		}
+	}
+	return resp, fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
+
+func (m *resilientModel) Model() string {
+	return m.primary.Model()
+}
+
+// call retries transient failures of the model, waiting at least as long as the model's Retry-After
+func (m *resilientModel) call(ctx context.Context, model *guardedModel, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if !model.breaker.allow() {
+		return openai.ChatCompletionResponse{}, ErrCircuitOpen
+	}
+	for attempt := 0; ; attempt++ {
+		hint := &retryHint{}
+		attemptCtx, cancel := context.WithTimeout(context.WithValue(ctx, retryHintKey{}, hint), m.timeout)
+		resp, err := model.CreateChatCompletion(attemptCtx, req)
+		cancel()
+		switch {
+		case err == nil:
+			model.breaker.success()
+			return resp, nil
+		case ctx.Err() != nil:
+			// the caller gave up, the model is not to blame
+			model.breaker.release()
+			return resp, ctx.Err()
+		case !transient(err):
+			model.breaker.release()
+			return resp, err
+		}
+
+		wait := m.backoff << attempt
+		if after := hint.get(); after > wait {
+			wait = after
+		}
+		if attempt >= m.retries || wait > m.maxBackoff {
+			model.breaker.failure()
+			return resp, err
+		}
+		m.log.WithField("model", model.Model()).Warnf("llm: attempt %d failed, retrying in %s: %v", attempt+1, wait, err)
+		select {
+		case <-ctx.Done():
+			model.breaker.release()
+			return resp, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// transient reports whether retrying the call may succeed
+func transient(err error) bool {
+	if errors.Is(err, ErrCassetteNotFound) {
+		return false
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		// the attempt timed out
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// unavailable reports whether the model could not be reached, as opposed to refusing the request
+func unavailable(err error) bool {
+	return err == ErrCircuitOpen || transient(err)
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= http.StatusInternalServerError
+}
+
+// breaker opens after threshold calls failed in a row, once cooldown has passed one call is let through to try the model again
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trying   bool
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	if b.trying || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trying = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.trying = 0, false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trying = false
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends a call that tells nothing about the model's health
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trying = false
+}
+
+type retryHintKey struct{}
+
+// retryHint carries the Retry-After of a failed response from the transport to the retry loop
+type retryHint struct {
+	mu   sync.Mutex
+	wait time.Duration
+}
+
+func (h *retryHint) get() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.wait
+}
+
+// retryAfterTransport reads the Retry-After header of failed responses into the request's retry hint
+type retryAfterTransport struct {
+	next http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil || res.StatusCode < http.StatusBadRequest {
+		return res, err
+	}
+	if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+		if wait, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			hint.mu.Lock()
+			hint.wait = wait
+			hint.mu.Unlock()
+		}
+	}
+	return res, nil
+}
+
+// parseRetryAfter reads delay seconds or an HTTP date
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
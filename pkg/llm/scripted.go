@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DriverScripted answers from a script file, for running the bot offline in tests and demos
+const DriverScripted = "scripted"
+
+type (
+	// Script is the content of an llm_script file, the first rule matching the user's message answers it
+	Script struct {
+		Model    string       `json:"model"`
+		Rules    []ScriptRule `json:"rules"`
+		Fallback string       `json:"fallback"`
+	}
+	// ScriptRule matches the last user message with a regular expression.
+	// A rule with a tool call asks for the tool first and then replies, $result in Reply is the tool's answer.
+	// $1, ${name} in Reply and in the tool arguments are the groups matched in the message.
+	// Error makes the model fail like an unavailable API, to exercise retries and the fallback.
+	ScriptRule struct {
+		Match    string          `json:"match"`
+		Reply    string          `json:"reply"`
+		ToolCall *ScriptToolCall `json:"tool_call,omitempty"`
+		Error    string          `json:"error,omitempty"`
+
+		re *regexp.Regexp
+	}
+	// ScriptToolCall is a tool the model asks to call. Arguments is a JSON object,
+	// or a string holding one when a group must expand to a number, e.g. "{\"photo_id\": $1}".
+	ScriptToolCall struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+)
+
+// arguments returns the template of the arguments
+func (c *ScriptToolCall) arguments() string {
+	var template string
+	if err := json.Unmarshal(c.Arguments, &template); err == nil {
+		return template
+	}
+	if len(c.Arguments) == 0 {
+		return "{}"
+	}
+	return string(c.Arguments)
+}
+
+// scriptedModel is a chat model that never leaves the process
+type scriptedModel struct {
+	script Script
+}
+
+// NewScriptedModel reads the script file and compiles its rules
+func NewScriptedModel(path string) (ChatModel, error) {
+	if path == "" {
+		return nil, errors.New("llm driver scripted needs llm_script, the path of a script file")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	script := Script{}
+	if err := json.Unmarshal(raw, &script); err != nil {
+		return nil, fmt.Errorf("llm script %s: %w", path, err)
+	}
+	for i := range script.Rules {
+		if script.Rules[i].re, err = regexp.Compile(script.Rules[i].Match); err != nil {
+			return nil, fmt.Errorf("llm script %s rule %d: %w", path, i, err)
+		}
+	}
+	if script.Model == "" {
+		script.Model = DriverScripted
+	}
+	return &scriptedModel{script: script}, nil
+}
+
+func (m *scriptedModel) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	turn := lastTurn(req.Messages)
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: m.script.Fallback}
+	for i, rule := range m.script.Rules {
+		if turn.tool != "" && (rule.ToolCall == nil || rule.ToolCall.Name != turn.tool) {
+			// the tool answered, only the rule that called it replies
+			continue
+		}
+		if turn.tool == "" && rule.ToolCall != nil && !offered(req.Tools, rule.ToolCall.Name) {
+			continue
+		}
+		match := rule.re.FindStringSubmatchIndex(turn.message)
+		if match == nil {
+			continue
+		}
+		if rule.Error != "" {
+			// looks like an outage of the model's API
+			return openai.ChatCompletionResponse{}, &openai.APIError{HTTPStatusCode: http.StatusServiceUnavailable, Message: rule.Error}
+		}
+		if rule.ToolCall != nil && turn.tool == "" {
+			arguments := rule.ToolCall.arguments()
+			src, escaped := escapeGroups(turn.message, match)
+			msg.Content = ""
+			msg.ToolCalls = []openai.ToolCall{{
+				ID:   fmt.Sprintf("call_%d", i),
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      rule.ToolCall.Name,
+					Arguments: string(rule.re.ExpandString(nil, arguments, src, escaped)),
+				},
+			}}
+			break
+		}
+		reply := strings.ReplaceAll(rule.Reply, "$result", "$$result")
+		msg.Content = strings.ReplaceAll(string(rule.re.ExpandString(nil, reply, turn.message, match)), "$result", turn.result)
+		break
+	}
+	return m.response(req, msg), nil
+}
+
+func (m *scriptedModel) Model() string {
+	return m.script.Model
+}
+
+// response wraps the message, token counts are estimated with CountTokens
+func (m *scriptedModel) response(req openai.ChatCompletionRequest, msg openai.ChatCompletionMessage) openai.ChatCompletionResponse {
+	prompt := CountTokens(req.Messages, req.Tools)
+	completion := TextTokens(msg.Content)
+	for _, call := range msg.ToolCalls {
+		completion += TextTokens(call.Function.Name) + TextTokens(call.Function.Arguments)
+	}
+	finish := openai.FinishReasonStop
+	if len(msg.ToolCalls) > 0 {
+		finish = openai.FinishReasonToolCalls
+	}
+	return openai.ChatCompletionResponse{
+		ID:      "scripted",
+		Object:  "chat.completion",
+		Model:   m.script.Model,
+		Choices: []openai.ChatCompletionChoice{{Message: msg, FinishReason: finish}},
+		Usage: openai.Usage{
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+			TotalTokens:      prompt + completion,
+		},
+	}
+}
+
+// turn is what the model is asked to answer
+type turn struct {
+	// message is the last user message
+	message string
+	// tool is set when the dialogue ends with the answers of a tool, result holds them
+	tool   string
+	result string
+}
+
+func lastTurn(messages []openai.ChatCompletionMessage) turn {
+	t := turn{}
+	i := len(messages) - 1
+	results := []string{}
+	for ; i >= 0 && messages[i].Role == openai.ChatMessageRoleTool; i-- {
+		results = append([]string{messages[i].Content}, results...)
+		t.tool = messages[i].Name
+	}
+	t.result = strings.Join(results, "\n")
+	for ; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleUser {
+			t.message = messages[i].Content
+			break
+		}
+	}
+	return t
+}
+
+func offered(tools []openai.Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Function != nil && tool.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeGroups returns the matched groups JSON escaped, with the match indexes into them,
+// so that $ references in tool arguments expand to text that keeps the arguments valid JSON
+func escapeGroups(message string, match []int) (string, []int) {
+	src := ""
+	escaped := make([]int, len(match))
+	for i := 0; i < len(match); i += 2 {
+		if match[i] < 0 {
+			escaped[i], escaped[i+1] = -1, -1
+			continue
+		}
+		quoted, _ := json.Marshal(message[match[i]:match[i+1]])
+		escaped[i] = len(src)
+		src += string(quoted[1 : len(quoted)-1])
+		escaped[i+1] = len(src)
+	}
+	return src, escaped
+}
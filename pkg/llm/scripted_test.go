@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const toolScript = `{
+  "rules": [
+    {
+      "match": "(?i)photos of (?P<username>\\S+)(?: from the (?P<album>\\w+) album)?",
+      "tool_call": {"name": "FetchPhotos", "arguments": {"username": "${username}", "album": "${album}"}},
+      "reply": "Photos of ${username}: $result"
+    },
+    {
+      "match": "(?i)trash photo (\\d+)",
+      "tool_call": {"name": "TrashPhoto", "arguments": "{\"photo_id\": $1}"},
+      "reply": "$result"
+    }
+  ]
+}`
+
+func TestScriptedToolArguments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	if err := os.WriteFile(path, []byte(toolScript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model, err := NewScriptedModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := []openai.Tool{
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "FetchPhotos"}},
+		{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "TrashPhoto"}},
+	}
+
+	tests := []struct {
+		message   string
+		arguments string
+	}{
+		// groups are JSON escaped so that quotes and backslashes keep the arguments valid
+		{`photos of a"b\c from the goa album`, `{"username": "a\"b\\c", "album": "goa"}`},
+		// an optional group that did not match expands to nothing
+		{`photos of user01`, `{"username": "user01", "album": ""}`},
+		// a string template expands numbers unquoted
+		{`trash photo 42`, `{"photo_id": 42}`},
+	}
+	for _, tt := range tests {
+		resp, err := model.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+			Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: tt.message}},
+			Tools:    tools,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := resp.Choices[0].Message.ToolCalls
+		if len(calls) != 1 {
+			t.Fatalf("%q: got %d tool calls, want 1", tt.message, len(calls))
+		}
+		if calls[0].Function.Arguments != tt.arguments {
+			t.Errorf("%q: arguments = %s, want %s", tt.message, calls[0].Function.Arguments, tt.arguments)
+		}
+	}
+}
+
+func TestScriptedReplyAfterTool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	if err := os.WriteFile(path, []byte(toolScript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	model, err := NewScriptedModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := model.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: "photos of user01"},
+			{Role: openai.ChatMessageRoleAssistant, ToolCalls: []openai.ToolCall{{ID: "call_0", Function: openai.FunctionCall{Name: "FetchPhotos"}}}},
+			// $1 in a tool result is not a group reference
+			{Role: openai.ChatMessageRoleTool, Name: "FetchPhotos", ToolCallID: "call_0", Content: "1: costs $1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Choices[0].Message.Content, "Photos of user01: 1: costs $1"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
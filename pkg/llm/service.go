@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// NewChatModelIn is function param struct of func `NewChatModel`
+type NewChatModelIn struct {
+	fx.In
+
+	Conf *viper.Viper
+	Log  *logrus.Logger
+}
+
+// driverSettings pick and configure a driver, the fallback model has its own
+type driverSettings struct {
+	driver  string
+	model   string
+	key     string
+	baseURL string
+}
+
+// openAIModel is a chat model behind the OpenAI API or an API compatible with it,
+// one client is shared by every request
+type openAIModel struct {
+	client *openai.Client
+	model  string
+}
+
+// NewChatModel returns the chat model of the configured driver. Calls time out, transient
+// failures are retried and, when llm_fallback_driver or llm_fallback_model is set, answered by the fallback model.
+func NewChatModel(i NewChatModelIn) (ChatModel, error) {
+	primary := driverSettings{
+		driver:  i.Conf.GetString(utils.LLMDriver),
+		model:   i.Conf.GetString(utils.LLMModel),
+		key:     i.Conf.GetString(utils.OpenAIAPIKey),
+		baseURL: i.Conf.GetString(utils.LLMBaseURL),
+	}
+	model, err := newDriver(i, primary)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback ChatModel
+	settings := driverSettings{
+		driver:  i.Conf.GetString(utils.LLMFallbackDriver),
+		model:   i.Conf.GetString(utils.LLMFallbackModel),
+		key:     i.Conf.GetString(utils.LLMFallbackAPIKey),
+		baseURL: i.Conf.GetString(utils.LLMFallbackBaseURL),
+	}
+	if settings.driver != "" || settings.model != "" {
+		// unset fallback settings are those of the primary model
+		if settings.driver == "" {
+			settings.driver = primary.driver
+		}
+		if settings.model == "" {
+			settings.model = primary.model
+		}
+		if settings.key == "" {
+			settings.key = primary.key
+		}
+		if settings.baseURL == "" && settings.driver == primary.driver {
+			settings.baseURL = primary.baseURL
+		}
+		if fallback, err = newDriver(i, settings); err != nil {
+			return nil, fmt.Errorf("llm fallback: %w", err)
+		}
+	}
+	return newResilientModel(i.Conf, i.Log, model, fallback), nil
+}
+
+func newDriver(i NewChatModelIn, settings driverSettings) (ChatModel, error) {
+	var config openai.ClientConfig
+	switch settings.driver {
+	case DriverOpenAI:
+		config = openai.DefaultConfig(settings.key)
+		if settings.baseURL != "" {
+			config.BaseURL = settings.baseURL
+		}
+	case DriverAzure:
+		if settings.baseURL == "" {
+			return nil, fmt.Errorf("llm driver azure needs llm_base_url, the resource endpoint")
+		}
+		deployments, err := parseDeployments(i.Conf.GetString(utils.LLMAzureDeployments))
+		if err != nil {
+			return nil, err
+		}
+		config = openai.DefaultAzureConfig(settings.key, settings.baseURL)
+		config.APIVersion = i.Conf.GetString(utils.LLMAzureAPIVersion)
+		config.AzureModelMapperFunc = func(model string) string {
+			if deployment, ok := deployments[model]; ok {
+				return deployment
+			}
+			// deployments named like their model need no mapping
+			return model
+		}
+	case DriverScripted:
+		i.Log.Warn("llm driver scripted, the bot answers from " + i.Conf.GetString(utils.LLMScript))
+		return NewScriptedModel(i.Conf.GetString(utils.LLMScript))
+	case DriverLocal:
+		if settings.baseURL == "" {
+			return nil, fmt.Errorf("llm driver local needs llm_base_url, e.g. http://localhost:11434/v1")
+		}
+		// local servers usually ignore the key but the client always sends one
+		key := settings.key
+		if key == "" {
+			key = "local"
+		}
+		config = openai.DefaultConfig(key)
+		config.BaseURL = settings.baseURL
+	default:
+		return nil, fmt.Errorf("unknown llm driver %q, want openai, azure, local or scripted", settings.driver)
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if mode := i.Conf.GetString(utils.LLMCassetteMode); mode != "" {
+		cassettes, err := newCassetteTransport(i.Log, mode, i.Conf.GetString(utils.LLMCassetteDir))
+		if err != nil {
+			return nil, err
+		}
+		i.Log.Warn("llm cassettes: " + mode + " " + i.Conf.GetString(utils.LLMCassetteDir))
+		transport = cassettes
+	}
+	config.HTTPClient = &http.Client{Transport: &retryAfterTransport{next: transport}}
+	i.Log.WithField("model", settings.model).Info("llm driver " + settings.driver)
+	return &openAIModel{client: openai.NewClientWithConfig(config), model: settings.model}, nil
+}
+
+func (m *openAIModel) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if req.Model == "" {
+		req.Model = m.model
+	}
+	return m.client.CreateChatCompletion(ctx, req)
+}
+
+func (m *openAIModel) Model() string {
+	return m.model
+}
+
+// parseDeployments reads space separated `<model>=<deployment>` pairs
+func parseDeployments(s string) (map[string]string, error) {
+	deployments := map[string]string{}
+	for _, field := range strings.Fields(s) {
+		model, deployment, ok := strings.Cut(field, "=")
+		if !ok || model == "" || deployment == "" {
+			return nil, fmt.Errorf("azure deployment %q: want <model>=<deployment>", field)
+		}
+		deployments[model] = deployment
+	}
+	return deployments, nil
+}
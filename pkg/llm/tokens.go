@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// tokens every message costs besides its content, and the tokens priming the answer
+const (
+	messageOverhead = 4
+	replyOverhead   = 3
+)
+
+// CountTokens estimates the prompt tokens of the messages and the tools offered with them.
+// It is not the model's tokenizer: ASCII text is counted as 4 characters a token and every
+// other character as a token of its own, which overestimates rather than underestimates
+// for the scripts a tokenizer splits finer than English.
+func CountTokens(messages []openai.ChatCompletionMessage, tools []openai.Tool) int {
+	tokens := replyOverhead
+	for _, message := range messages {
+		tokens += messageOverhead + TextTokens(message.Content) + TextTokens(message.Name)
+		for _, call := range message.ToolCalls {
+			tokens += messageOverhead + TextTokens(call.Function.Name) + TextTokens(call.Function.Arguments)
+		}
+	}
+	if len(tools) > 0 {
+		if b, err := json.Marshal(tools); err == nil {
+			tokens += TextTokens(string(b))
+		}
+	}
+	return tokens
+}
+
+// TextTokens estimates the tokens of the text
+func TextTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
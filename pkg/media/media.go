@@ -0,0 +1,93 @@
+// Package media holds the image processing used by background photo jobs.
+package media
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+	"golang.org/x/image/draw"
+)
+
+const thumbnailQuality = 80
+
+// Metadata is the subset of EXIF tags we keep for a photo
+type Metadata struct {
+	TakenAt   time.Time
+	Latitude  float64
+	Longitude float64
+	HasGPS    bool
+	// Tags are all string and numeric EXIF tags by name, e.g. Make, Model, Orientation
+	Tags map[string]string
+}
+
+// Thumbnail decodes a JPEG, PNG or GIF image and returns a JPEG whose longest side is
+// at most maxSize pixels. Images that are already small enough are only re-encoded.
+func Thumbnail(r io.Reader, maxSize int) ([]byte, error) {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			w, h = maxSize, h*maxSize/w
+		} else {
+			w, h = w*maxSize/h, maxSize
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadMetadata parses the EXIF data of a JPEG or TIFF image
+func ReadMetadata(r io.Reader) (*Metadata, error) {
+	x, err := exif.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	md := &Metadata{Tags: map[string]string{}}
+	if t, err := x.DateTime(); err == nil {
+		md.TakenAt = t
+	}
+	if lat, long, err := x.LatLong(); err == nil {
+		md.Latitude, md.Longitude, md.HasGPS = lat, long, true
+	}
+	x.Walk(walker(md.Tags))
+	return md, nil
+}
+
+// walker collects EXIF tags into a map, skipping binary blobs like MakerNote
+type walker map[string]string
+
+func (w walker) Walk(name exif.FieldName, tag *tiff.Tag) error {
+	switch tag.Format() {
+	case tiff.StringVal:
+		if v, err := tag.StringVal(); err == nil {
+			w[string(name)] = strings.TrimSpace(v)
+		}
+	case tiff.IntVal, tiff.RatVal, tiff.FloatVal:
+		w[string(name)] = tag.String()
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	insertMemory(context.Context, *Memory) (bool, error)
+	retrieveMemories(context.Context, int) ([]Memory, error)
+	countMemories(context.Context, int) (int, error)
+	deleteMemory(context.Context, int, int) error
+	deleteMemories(context.Context, int) (int, error)
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+// insertMemory stores the fact unless the user remembers it already, ignoring case.
+// It reports whether the fact was stored.
+func (r *PGRepo) insertMemory(ctx context.Context, memory *Memory) (bool, error) {
+	res, err := r.db.ModelContext(ctx, memory).
+		OnConflict("(user_id, (lower(content))) DO NOTHING").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// retrieveMemories returns the facts of the user, oldest first
+func (r *PGRepo) retrieveMemories(ctx context.Context, userID int) ([]Memory, error) {
+	memories := []Memory{}
+	err := r.db.ModelContext(ctx, &memories).
+		Where("user_id = ?", userID).
+		Order("id").
+		Select()
+	return memories, err
+}
+
+func (r *PGRepo) countMemories(ctx context.Context, userID int) (int, error) {
+	return r.db.ModelContext(ctx, (*Memory)(nil)).
+		Where("user_id = ?", userID).
+		Count()
+}
+
+// deleteMemory deletes a fact of the user, it returns pg.ErrNoRows when the user has no such fact
+func (r *PGRepo) deleteMemory(ctx context.Context, userID, id int) error {
+	res, err := r.db.ModelContext(ctx, (*Memory)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+// deleteMemories deletes every fact of the user and returns how many there were
+func (r *PGRepo) deleteMemories(ctx context.Context, userID int) (int, error) {
+	res, err := r.db.ModelContext(ctx, (*Memory)(nil)).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
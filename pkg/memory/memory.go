@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the store of the facts the bot remembers about users
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+type (
+	// Memory is a fact about a user the bot remembers across chat sessions,
+	// e.g. "my trips are always in the Goa album". A user remembers a fact once.
+	Memory struct {
+		tableName struct{}  `pg:"memories,alias:memory,discard_unknown_columns"`
+		ID        int       `json:"id" pg:"id,pk"`
+		UserID    int       `json:"-" pg:"user_id"`
+		Content   string    `json:"content" pg:"content"`
+		CreatedAt time.Time `json:"created_at" pg:"created_at"`
+	}
+)
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// MemRepo keeps the facts in memory, it backs tests that run without postgres
+type MemRepo struct {
+	mu       sync.Mutex
+	nextID   int
+	memories []Memory
+}
+
+// NewMemRepository returns an empty in-memory persistence layer
+func NewMemRepository() Repository {
+	return &MemRepo{}
+}
+
+// insertMemory stores the fact unless the user remembers it already, ignoring case.
+// It reports whether the fact was stored.
+func (r *MemRepo) insertMemory(ctx context.Context, memory *Memory) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.memories {
+		if m.UserID == memory.UserID && strings.EqualFold(m.Content, memory.Content) {
+			return false, nil
+		}
+	}
+	r.nextID++
+	memory.ID = r.nextID
+	r.memories = append(r.memories, *memory)
+	return true, nil
+}
+
+// retrieveMemories returns the facts of the user, oldest first
+func (r *MemRepo) retrieveMemories(ctx context.Context, userID int) ([]Memory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	memories := []Memory{}
+	for _, m := range r.memories {
+		if m.UserID == userID {
+			memories = append(memories, m)
+		}
+	}
+	return memories, nil
+}
+
+func (r *MemRepo) countMemories(ctx context.Context, userID int) (int, error) {
+	memories, err := r.retrieveMemories(ctx, userID)
+	return len(memories), err
+}
+
+// deleteMemory deletes a fact of the user, it returns pg.ErrNoRows when the user has no such fact
+func (r *MemRepo) deleteMemory(ctx context.Context, userID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, m := range r.memories {
+		if m.ID == id && m.UserID == userID {
+			r.memories = append(r.memories[:i], r.memories[i+1:]...)
+			return nil
+		}
+	}
+	return pg.ErrNoRows
+}
+
+// deleteMemories deletes every fact of the user and returns how many there were
+func (r *MemRepo) deleteMemories(ctx context.Context, userID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := []Memory{}
+	for _, m := range r.memories {
+		if m.UserID != userID {
+			kept = append(kept, m)
+		}
+	}
+	deleted := len(r.memories) - len(kept)
+	r.memories = kept
+	return deleted, nil
+}
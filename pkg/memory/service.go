@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/utils"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrMemoryNotFound = errors.New("memory not found")
+	ErrMemoryExists   = errors.New("the fact is remembered already")
+	ErrMemoryFull     = errors.New("too many facts are remembered, forget one first")
+	ErrInvalidMemory  = errors.New("invalid fact")
+)
+
+// maxMemoryLength is the longest fact in characters
+const maxMemoryLength = 300
+
+type Service struct {
+	conf *viper.Viper
+	log  *logrus.Logger
+	Repo Repository
+}
+
+// NewService returns a user memory service object.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository) *Service {
+	return &Service{
+		conf: conf,
+		log:  log,
+		Repo: Repo,
+	}
+}
+
+// Memories returns the facts remembered about the user, oldest first
+func (s *Service) Memories(ctx context.Context, userID int) ([]Memory, error) {
+	return s.Repo.retrieveMemories(ctx, userID)
+}
+
+// Save remembers the fact about the user. A user remembers at most memory_max_facts facts,
+// saving the same fact again returns ErrMemoryExists.
+func (s *Service) Save(ctx context.Context, userID int, content string) (*Memory, error) {
+	content = strings.Join(strings.Fields(content), " ")
+	if content == "" {
+		return nil, fmt.Errorf("%w: the fact is empty", ErrInvalidMemory)
+	}
+	if utf8.RuneCountInString(content) > maxMemoryLength {
+		return nil, fmt.Errorf("%w: a fact has at most %d characters", ErrInvalidMemory, maxMemoryLength)
+	}
+	count, err := s.Repo.countMemories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if max := s.conf.GetInt(utils.MemoryMaxFacts); max > 0 && count >= max {
+		return nil, ErrMemoryFull
+	}
+	memory := &Memory{
+		UserID:    userID,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	stored, err := s.Repo.insertMemory(ctx, memory)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		return nil, ErrMemoryExists
+	}
+	return memory, nil
+}
+
+// Forget deletes a fact remembered about the user
+func (s *Service) Forget(ctx context.Context, userID, id int) error {
+	err := s.Repo.deleteMemory(ctx, userID, id)
+	if err == pg.ErrNoRows {
+		return ErrMemoryNotFound
+	}
+	return err
+}
+
+// ForgetAll deletes every fact remembered about the user and returns how many there were
+func (s *Service) ForgetAll(ctx context.Context, userID int) (int, error) {
+	return s.Repo.deleteMemories(ctx, userID)
+}
+
+// Relevant returns up to memory_prompt_facts facts about the user to answer the message with,
+// oldest first. Facts sharing the most words with the message are picked, then the latest ones,
+// so that standing preferences like "reply in Hindi" are kept while the user has few facts.
+func (s *Service) Relevant(ctx context.Context, userID int, message string) ([]Memory, error) {
+	memories, err := s.Repo.retrieveMemories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	limit := s.conf.GetInt(utils.MemoryPromptFacts)
+	if limit <= 0 || len(memories) <= limit {
+		return memories, nil
+	}
+	asked := map[string]bool{}
+	for _, word := range words(message) {
+		asked[word] = true
+	}
+	scores := map[int]int{}
+	for _, memory := range memories {
+		for _, word := range words(memory.Content) {
+			if asked[word] {
+				scores[memory.ID]++
+			}
+		}
+	}
+	ranked := append([]Memory{}, memories...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if scores[ranked[i].ID] != scores[ranked[j].ID] {
+			return scores[ranked[i].ID] > scores[ranked[j].ID]
+		}
+		return ranked[i].ID > ranked[j].ID
+	})
+	ranked = ranked[:limit]
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].ID < ranked[j].ID })
+	return ranked, nil
+}
+
+// words returns the lower case words of the text with at least 3 letters or digits
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	words := []string{}
+	for _, field := range fields {
+		if utf8.RuneCountInString(field) >= 3 {
+			words = append(words, field)
+		}
+	}
+	return words
+}
@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+// Module provides the notification hub used to push messages to open websockets
+var Module = fx.Options(
+	fx.Provide(
+		NewService,
+	),
+)
+
+const (
+	// channel is the redis pub/sub channel that carries messages between
+	// the worker and server processes
+	channel          = "notify"
+	subscriberBuffer = 32
+	reconnectDelay   = time.Second
+)
+
+// Message is a notification for a single user, or for everyone when Username is empty
+type Message struct {
+	Username string `json:"username"`
+	Text     string `json:"text"`
+}
+
+// NewServiceIn is function param struct of func `NewService`
+type NewServiceIn struct {
+	fx.In
+
+	Log  *logrus.Logger
+	Pool *redis.Pool `name:"redisWorker"`
+}
+
+type Service struct {
+	log    *logrus.Logger
+	pool   *redis.Pool
+	listen sync.Once
+	mu     sync.RWMutex
+	next   int
+	subs   map[int]chan Message
+}
+
+// NewService returns a notification hub.
+func NewService(i NewServiceIn) *Service {
+	return &Service{
+		log:  i.Log,
+		pool: i.Pool,
+		subs: map[int]chan Message{},
+	}
+}
+
+// Publish sends a message to the subscribers of every process.
+func (s *Service) Publish(username, text string) {
+	data, err := json.Marshal(Message{Username: username, Text: text})
+	if err != nil {
+		s.log.Error("notify: " + err.Error())
+		return
+	}
+	conn := s.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PUBLISH", channel, data); err != nil {
+		s.log.WithField("username", username).Error("notify: publish failed: " + err.Error())
+	}
+}
+
+// Subscribe returns a channel receiving every published message and
+// a function that must be called to stop the subscription.
+func (s *Service) Subscribe() (<-chan Message, func()) {
+	s.listen.Do(func() {
+		go s.receiveLoop()
+	})
+
+	ch := make(chan Message, subscriberBuffer)
+	s.mu.Lock()
+	id := s.next
+	s.next++
+	s.subs[id] = ch
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, id)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// dispatch hands a message to the local subscribers.
+// Subscribers that are not keeping up miss the message instead of blocking the others.
+func (s *Service) dispatch(msg Message) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, ch := range s.subs {
+		select {
+		case ch <- msg:
+		default:
+			s.log.WithField("username", msg.Username).Warn("notify: dropped message for slow subscriber")
+		}
+	}
+}
+
+// receiveLoop listens to the redis channel on a dedicated connection, reconnecting on errors
+func (s *Service) receiveLoop() {
+	for {
+		if err := s.receive(); err != nil {
+			s.log.Error("notify: subscription failed: " + err.Error())
+		}
+		time.Sleep(reconnectDelay)
+	}
+}
+
+func (s *Service) receive() error {
+	// pub/sub holds its connection for good, so it is not taken from the pool
+	conn, err := s.pool.Dial()
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+	if err := psc.Subscribe(channel); err != nil {
+		return err
+	}
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			msg := Message{}
+			if err := json.Unmarshal(v.Data, &msg); err != nil {
+				s.log.Error("notify: " + err.Error())
+				continue
+			}
+			s.dispatch(msg)
+		case error:
+			return v
+		}
+	}
+}
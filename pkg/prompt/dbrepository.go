@@ -0,0 +1,112 @@
+package prompt
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	insertVersion(context.Context, *Prompt) error
+	retrieveVersions(context.Context, string) ([]Prompt, error)
+	fetchVersion(context.Context, string, int) (*Prompt, error)
+	fetchActive(context.Context, string) (*Prompt, error)
+	fetchPreviousVersion(context.Context, string) (*Prompt, error)
+	activate(context.Context, *ActivePrompt) error
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+// insertVersion stores the prompt as the next version of its name
+func (r *PGRepo) insertVersion(ctx context.Context, prompt *Prompt) error {
+	return r.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		// versions of a name are numbered one at a time
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext(?))", "prompt:"+prompt.Name); err != nil {
+			return err
+		}
+		err := tx.ModelContext(ctx, (*Prompt)(nil)).
+			ColumnExpr("COALESCE(MAX(version), 0) + 1").
+			Where("name = ?", prompt.Name).
+			Select(pg.Scan(&prompt.Version))
+		if err != nil {
+			return err
+		}
+		_, err = tx.ModelContext(ctx, prompt).Insert()
+		return err
+	})
+}
+
+func (r *PGRepo) retrieveVersions(ctx context.Context, name string) ([]Prompt, error) {
+	prompts := []Prompt{}
+	err := r.db.ModelContext(ctx, &prompts).
+		Where("name = ?", name).
+		Order("version DESC").
+		Select()
+	return prompts, err
+}
+
+func (r *PGRepo) fetchVersion(ctx context.Context, name string, version int) (*Prompt, error) {
+	prompt := &Prompt{}
+	err := r.db.ModelContext(ctx, prompt).
+		Where("name = ?", name).
+		Where("version = ?", version).
+		Select()
+	return prompt, err
+}
+
+func (r *PGRepo) fetchActive(ctx context.Context, name string) (*Prompt, error) {
+	prompt := &Prompt{}
+	err := r.db.ModelContext(ctx, prompt).
+		Join("JOIN active_prompts AS a ON a.prompt_id = prompt.id").
+		Where("a.name = ?", name).
+		Select()
+	return prompt, err
+}
+
+// fetchPreviousVersion returns the latest version older than the active one
+func (r *PGRepo) fetchPreviousVersion(ctx context.Context, name string) (*Prompt, error) {
+	prompt := &Prompt{}
+	err := r.db.ModelContext(ctx, prompt).
+		Where("prompt.name = ?", name).
+		Where("prompt.version < (SELECT p.version FROM prompts AS p JOIN active_prompts AS a ON a.prompt_id = p.id WHERE a.name = ?)", name).
+		Order("version DESC").
+		Limit(1).
+		Select()
+	return prompt, err
+}
+
+func (r *PGRepo) activate(ctx context.Context, active *ActivePrompt) error {
+	_, err := r.db.ModelContext(ctx, active).
+		OnConflict("(name) DO UPDATE").
+		Set("prompt_id = EXCLUDED.prompt_id").
+		Set("activated_by = EXCLUDED.activated_by").
+		Set("activated_at = EXCLUDED.activated_at").
+		Insert()
+	return err
+}
@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// MemRepo keeps prompt versions in memory, it backs tests that run without postgres
+type MemRepo struct {
+	mu      sync.Mutex
+	prompts []Prompt
+	active  map[string]ActivePrompt
+}
+
+// NewMemRepository returns an empty in-memory persistence layer
+func NewMemRepository() Repository {
+	return &MemRepo{active: map[string]ActivePrompt{}}
+}
+
+// insertVersion stores the prompt as the next version of its name
+func (r *MemRepo) insertVersion(ctx context.Context, prompt *Prompt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prompt.Version = 1
+	for _, p := range r.prompts {
+		if p.Name == prompt.Name && p.Version >= prompt.Version {
+			prompt.Version = p.Version + 1
+		}
+	}
+	prompt.ID = len(r.prompts) + 1
+	r.prompts = append(r.prompts, *prompt)
+	return nil
+}
+
+func (r *MemRepo) retrieveVersions(ctx context.Context, name string) ([]Prompt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prompts := []Prompt{}
+	// versions are inserted in order, the latest is listed first
+	for i := len(r.prompts) - 1; i >= 0; i-- {
+		if r.prompts[i].Name == name {
+			prompts = append(prompts, r.prompts[i])
+		}
+	}
+	return prompts, nil
+}
+
+func (r *MemRepo) fetchVersion(ctx context.Context, name string, version int) (*Prompt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.prompts {
+		if p.Name == name && p.Version == version {
+			return &p, nil
+		}
+	}
+	return &Prompt{}, pg.ErrNoRows
+}
+
+func (r *MemRepo) fetchActive(ctx context.Context, name string) (*Prompt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active, ok := r.active[name]
+	if !ok {
+		return &Prompt{}, pg.ErrNoRows
+	}
+	return &r.prompts[active.PromptID-1], nil
+}
+
+// fetchPreviousVersion returns the latest version older than the active one
+func (r *MemRepo) fetchPreviousVersion(ctx context.Context, name string) (*Prompt, error) {
+	active, err := r.fetchActive(ctx, name)
+	if err != nil {
+		return active, err
+	}
+	versions, _ := r.retrieveVersions(ctx, name)
+	for _, p := range versions {
+		if p.Version < active.Version {
+			return &p, nil
+		}
+	}
+	return &Prompt{}, pg.ErrNoRows
+}
+
+func (r *MemRepo) activate(ctx context.Context, active *ActivePrompt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active[active.Name] = *active
+	return nil
+}
@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the managed prompts
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+// names of the built-in prompts
+const (
+	// System is the prompt holding the bot's persona and instructions
+	System = "system"
+	// Summary instructs the model to summarize the older turns of a long chat
+	Summary = "summary"
+)
+
+// Defaults are used for prompts without an active version, the messages are templates rendered with a Context
+var Defaults = map[string][]string{
+	System: {
+		"You can help to upload photos ask the user to click upload button below and upload ?",
+		"you will ask the user if they want to upload photos or retrieve them ?",
+		"You are Alexia, a helpful AI assistant",
+		`{{if .User.FirstName}}The user's name is {{.User.FirstName}}. {{end}}` +
+			`They have {{.Photos.Count}} photos{{if .Photos.Albums}} in the albums {{join .Photos.Albums ", "}}{{end}}` +
+			`{{if .Photos.Trashed}} and {{.Photos.Trashed}} in the trash{{end}}.`,
+		`It is {{.Now.Format "Monday, 2 January 2006 15:04"}} UTC. Reply in the language of the locale {{.Locale}} unless the user writes in another one.`,
+		`{{if .Memories}}The user asked you to remember: {{join .Memories "; "}}. Follow these unless the user says otherwise now.{{end}}`,
+		"When the user tells you a lasting preference or fact about themselves or their photos, e.g. which album their trips go to, save it with SaveMemory.",
+	},
+	Summary: {
+		"Summarize the conversation between the user and the photo assistant so that the assistant can continue it from the summary alone.",
+		"Keep what the user asked for, the photos, albums and usernames they mentioned, what was done on their account and what is still open. Leave out greetings and small talk.",
+		"Write at most 200 words in the language of the conversation, without a title or a preamble.",
+	},
+}
+
+type (
+	// Prompt is one version of a named prompt, every message is sent as a system message
+	Prompt struct {
+		tableName struct{}  `pg:"prompts,discard_unknown_columns"`
+		ID        int       `json:"id" pg:"id,pk"`
+		Name      string    `json:"name" pg:"name"`
+		Version   int       `json:"version" pg:"version,use_zero"`
+		Messages  []string  `json:"messages" pg:"messages,array"`
+		Note      string    `json:"note,omitempty" pg:"note"`
+		CreatedBy int       `json:"created_by" pg:"created_by"`
+		CreatedAt time.Time `json:"created_at" pg:"created_at"`
+		// Active is set on the active version when listing versions
+		Active bool `json:"active" pg:"-"`
+	}
+	// ActivePrompt points to the version of a prompt the bot uses
+	ActivePrompt struct {
+		tableName   struct{}  `pg:"active_prompts,discard_unknown_columns"`
+		Name        string    `json:"name" pg:"name,pk"`
+		PromptID    int       `json:"prompt_id" pg:"prompt_id"`
+		ActivatedBy int       `json:"activated_by" pg:"activated_by"`
+		ActivatedAt time.Time `json:"activated_at" pg:"activated_at"`
+	}
+)
@@ -0,0 +1,199 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/pkg/cache"
+	"uber_fx_init_folder_structure/pkg/cache/persistence"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrPromptNotFound = errors.New("prompt not found")
+	ErrEmptyPrompt    = errors.New("a prompt needs at least one non empty message")
+	ErrNoPrevious     = errors.New("there is no older version to roll back to")
+)
+
+type Service struct {
+	conf  *viper.Viper
+	log   *logrus.Logger
+	Repo  Repository
+	cache *cache.Service
+}
+
+// NewService returns a managed prompt service object, it fails when a built-in prompt is not a valid template.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, cache *cache.Service) (*Service, error) {
+	for name, messages := range Defaults {
+		if _, err := Parse(messages); err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", name, err)
+		}
+	}
+	return &Service{
+		conf:  conf,
+		log:   log,
+		Repo:  Repo,
+		cache: cache,
+	}, nil
+}
+
+func cacheKey(name string) string {
+	return "prompt:active:" + name
+}
+
+// Versions returns every version of the prompt, latest first, with the active one marked
+func (s *Service) Versions(ctx context.Context, name string) ([]Prompt, error) {
+	prompts, err := s.Repo.retrieveVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.Repo.fetchActive(ctx, name)
+	if err != nil && err != pg.ErrNoRows {
+		return nil, err
+	}
+	for i := range prompts {
+		prompts[i].Active = active != nil && prompts[i].ID == active.ID
+	}
+	return prompts, nil
+}
+
+// Version returns one version of the prompt
+func (s *Service) Version(ctx context.Context, name string, version int) (*Prompt, error) {
+	prompt, err := s.Repo.fetchVersion(ctx, name, version)
+	if err == pg.ErrNoRows {
+		return nil, ErrPromptNotFound
+	}
+	return prompt, err
+}
+
+// Create stores the messages as the next version of the prompt, it is not used until it is activated.
+// It returns ErrInvalidTemplate when a message is not a valid template.
+func (s *Service) Create(ctx context.Context, name string, messages []string, note string, createdBy int) (*Prompt, error) {
+	prompt := &Prompt{
+		Name:      strings.ToLower(strings.TrimSpace(name)),
+		Messages:  []string{},
+		Note:      note,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	for _, message := range messages {
+		if message = strings.TrimSpace(message); message != "" {
+			prompt.Messages = append(prompt.Messages, message)
+		}
+	}
+	if prompt.Name == "" || len(prompt.Messages) == 0 {
+		return nil, ErrEmptyPrompt
+	}
+	if _, err := Parse(prompt.Messages); err != nil {
+		return nil, err
+	}
+	return prompt, s.Repo.insertVersion(ctx, prompt)
+}
+
+// Activate makes the version the one the bot uses
+func (s *Service) Activate(ctx context.Context, name string, version int, activatedBy int) (*Prompt, error) {
+	prompt, err := s.Version(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return prompt, s.activate(ctx, prompt, activatedBy)
+}
+
+// Rollback activates the latest version older than the active one
+func (s *Service) Rollback(ctx context.Context, name string, activatedBy int) (*Prompt, error) {
+	prompt, err := s.Repo.fetchPreviousVersion(ctx, name)
+	if err == pg.ErrNoRows {
+		return nil, ErrNoPrevious
+	}
+	if err != nil {
+		return nil, err
+	}
+	return prompt, s.activate(ctx, prompt, activatedBy)
+}
+
+func (s *Service) activate(ctx context.Context, prompt *Prompt, activatedBy int) error {
+	err := s.Repo.activate(ctx, &ActivePrompt{
+		Name:        prompt.Name,
+		PromptID:    prompt.ID,
+		ActivatedBy: activatedBy,
+		ActivatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.cache.Delete(cacheKey(prompt.Name)); err != nil && err != persistence.ErrCacheMiss {
+		s.log.WithField("prompt", prompt.Name).Error("failed to clear the cached prompt: " + err.Error())
+	}
+	return nil
+}
+
+// Active returns the active version of the prompt, or its defaults as version 0 when no
+// version was activated. The active version is cached for llm_prompt_cache_ttl.
+func (s *Service) Active(ctx context.Context, name string) (*Prompt, error) {
+	return s.cached(cacheKey(name), func() (*Prompt, error) {
+		prompt, err := s.Repo.fetchActive(ctx, name)
+		if err == pg.ErrNoRows && Defaults[name] != nil {
+			return &Prompt{Name: name, Messages: Defaults[name]}, nil
+		}
+		if err == pg.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+		}
+		return prompt, err
+	})
+}
+
+// Render returns the version of the prompt with its messages rendered with the context,
+// the active version when version is 0
+func (s *Service) Render(ctx context.Context, name string, version int, data Context) (*Prompt, error) {
+	var (
+		prompt *Prompt
+		err    error
+	)
+	if version == 0 {
+		prompt, err = s.Active(ctx, name)
+	} else {
+		// versions never change once stored
+		prompt, err = s.cached(fmt.Sprintf("prompt:%s:%d", name, version), func() (*Prompt, error) {
+			return s.Version(ctx, name, version)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	rendered := *prompt
+	if rendered.Messages, err = Render(prompt.Messages, data); err != nil {
+		return nil, err
+	}
+	return &rendered, nil
+}
+
+// cached returns the prompt cached under key, or loads and caches it for llm_prompt_cache_ttl
+func (s *Service) cached(key string, load func() (*Prompt, error)) (*Prompt, error) {
+	var cached string
+	if err := s.cache.Get(key, &cached); err == nil {
+		prompt := &Prompt{}
+		if err := json.Unmarshal([]byte(cached), prompt); err == nil {
+			return prompt, nil
+		}
+	}
+
+	prompt, err := load()
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(prompt)
+	if err == nil {
+		err = s.cache.Set(key, string(b), s.conf.GetDuration(utils.LLMPromptCacheTTL))
+	}
+	if err != nil {
+		s.log.WithField("prompt", prompt.Name).Error("failed to cache the prompt: " + err.Error())
+	}
+	return prompt, nil
+}
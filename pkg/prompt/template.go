@@ -0,0 +1,98 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var ErrInvalidTemplate = errors.New("invalid prompt template")
+
+type (
+	// Context is what the messages of a prompt are rendered with, it is built before each completion
+	Context struct {
+		User    UserContext
+		Session SessionContext
+		Photos  PhotoContext
+		// Memories are the facts the bot remembers about the user that are most relevant to the message
+		Memories []string
+		// Locale is the language tag the user's client asked for, e.g. en-IN
+		Locale string
+		// Now is the time of the message in UTC
+		Now time.Time
+	}
+	UserContext struct {
+		Username  string
+		FirstName string
+		LastName  string
+		Email     string
+		// SignedIn is false when the messages are sent by a script with an API key
+		SignedIn bool
+	}
+	SessionContext struct {
+		ID string
+		// Tokens is what the session has used so far, 0 for the first message
+		Tokens int
+	}
+	PhotoContext struct {
+		// Count is the number of photos not in the trash
+		Count   int
+		Trashed int
+		Albums  []string
+	}
+)
+
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// sample is the context templates are test rendered with, every field is set so that
+// a template fails on a misspelled field rather than on an empty value
+var sample = Context{
+	User:     UserContext{Username: "user01", FirstName: "Asha", LastName: "Rao", Email: "user01@example.com", SignedIn: true},
+	Session:  SessionContext{ID: "00000000-0000-0000-0000-000000000000", Tokens: 1200},
+	Photos:   PhotoContext{Count: 42, Trashed: 3, Albums: []string{"beach", "goa"}},
+	Memories: []string{"my trips are always in the goa album"},
+	Locale:   "en",
+	Now:      time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC),
+}
+
+// Parse parses every message as a template and renders it with a sample context,
+// so that a mistake is reported when the prompt is stored rather than when a user chats
+func Parse(messages []string) ([]*template.Template, error) {
+	templates := make([]*template.Template, len(messages))
+	for i, message := range messages {
+		t, err := template.New(fmt.Sprintf("message %d", i+1)).Option("missingkey=error").Funcs(funcs).Parse(message)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		if err := t.Execute(&strings.Builder{}, sample); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		templates[i] = t
+	}
+	return templates, nil
+}
+
+// Render renders the messages with the context, messages that render empty are dropped
+func Render(messages []string, data Context) ([]string, error) {
+	templates, err := Parse(messages)
+	if err != nil {
+		return nil, err
+	}
+	rendered := []string{}
+	for _, t := range templates {
+		b := &strings.Builder{}
+		if err := t.Execute(b, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		if content := strings.TrimSpace(b.String()); content != "" {
+			rendered = append(rendered, content)
+		}
+	}
+	return rendered, nil
+}
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the job queue used to enqueue jobs and register their handlers
+var Module = fx.Options(
+	fx.Provide(
+		NewService,
+	),
+)
+
+// WorkerModule consumes the queue, it is only started in `worker` mode
+var WorkerModule = fx.Options(
+	fx.Invoke(
+		StartWorker,
+	),
+)
+
+// HandlerFunc processes a job. A returned error makes the job retry with backoff
+// until it runs out of attempts and is moved to the dead letter queue.
+type HandlerFunc func(ctx context.Context, job Job) error
+
+type (
+	// Job is a unit of background work stored in redis
+	Job struct {
+		ID          string          `json:"id"`
+		Type        string          `json:"type"`
+		Payload     json.RawMessage `json:"payload"`
+		Attempts    int             `json:"attempts"`
+		MaxAttempts int             `json:"max_attempts"`
+		LastError   string          `json:"last_error,omitempty"`
+		EnqueuedAt  time.Time       `json:"enqueued_at"`
+		FailedAt    time.Time       `json:"failed_at,omitempty"`
+	}
+	schedule struct {
+		jobType string
+		every   time.Duration
+	}
+)
+
+// Decode unmarshals the job payload into v
+func (j Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// LastAttempt reports whether a failure of the current run moves the job to the dead letter queue
+func (j Job) LastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
@@ -0,0 +1,351 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// redis keys, a job id moves between ready, inflight and delayed while its data stays in jobsKey
+const (
+	jobsKey     = "queue:jobs"
+	readyKey    = "queue:ready"
+	inflightKey = "queue:inflight"
+	delayedKey  = "queue:delayed"
+	deadKey     = "queue:dead"
+	scheduleKey = "queue:schedule:"
+
+	maxBackoff     = time.Hour
+	promoteBatch   = 100
+	maxDeadLetters = 1000
+	// settleTimeout bounds acking or failing a job, the handler may have used up the visibility timeout
+	settleTimeout = 5 * time.Second
+)
+
+var (
+	ErrUnknownJobType    = errors.New("queue: no handler registered for job type")
+	ErrVisibilityTimeout = errors.New("queue: visibility timeout expired on the last attempt")
+)
+
+var (
+	// reserveScript moves the oldest ready job to inflight until its visibility deadline
+	reserveScript = redis.NewScript(2, `
+local id = redis.call('RPOP', KEYS[1])
+if not id then return false end
+redis.call('ZADD', KEYS[2], ARGV[1], id)
+return id`)
+
+	// promoteScript moves every job of a sorted set whose score is due back to ready
+	promoteScript = redis.NewScript(2, `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+  redis.call('ZREM', KEYS[1], id)
+  redis.call('LPUSH', KEYS[2], id)
+end
+return #ids`)
+
+	// ackScript removes a finished job, unless its visibility timeout expired and
+	// it was already handed to another worker
+	ackScript = redis.NewScript(2, `
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+  redis.call('HDEL', KEYS[2], ARGV[1])
+  return 1
+end
+return 0`)
+
+	// retryScript schedules a failed job to run again at ARGV[2]
+	retryScript = redis.NewScript(3, `
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+  redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+  redis.call('ZADD', KEYS[3], ARGV[2], ARGV[1])
+  return 1
+end
+return 0`)
+
+	// deadScript moves a failed job to the dead letter list
+	deadScript = redis.NewScript(3, `
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('LPUSH', KEYS[3], ARGV[2])
+redis.call('LTRIM', KEYS[3], 0, ARGV[3])
+return 1`)
+)
+
+// NewServiceIn is function param struct of func `NewService`
+type NewServiceIn struct {
+	fx.In
+
+	Conf *viper.Viper
+	Log  *logrus.Logger
+	Pool *redis.Pool `name:"redisWorker"`
+}
+
+type Service struct {
+	conf      *viper.Viper
+	log       *logrus.Logger
+	pool      *redis.Pool
+	handlers  map[string]HandlerFunc
+	schedules []schedule
+}
+
+// NewService returns a redis backed job queue.
+func NewService(i NewServiceIn) *Service {
+	return &Service{
+		conf:     i.Conf,
+		log:      i.Log,
+		pool:     i.Pool,
+		handlers: map[string]HandlerFunc{},
+	}
+}
+
+// Register sets the handler of a job type.
+// Handlers must be registered during app startup, before the worker starts.
+func (s *Service) Register(jobType string, fn HandlerFunc) {
+	s.handlers[jobType] = fn
+}
+
+// Schedule makes the worker enqueue a payload-less job of the given type every interval.
+// However many workers run, the job is enqueued once per interval.
+func (s *Service) Schedule(jobType string, every time.Duration) {
+	if every <= 0 {
+		s.log.WithField("type", jobType).Warn("queue: schedule disabled, interval is not positive")
+		return
+	}
+	s.schedules = append(s.schedules, schedule{jobType: jobType, every: every})
+}
+
+// Enqueue adds a job to the queue, payload is stored as JSON
+func (s *Service) Enqueue(ctx context.Context, jobType string, payload interface{}) (string, error) {
+	return s.EnqueueIn(ctx, jobType, payload, 0)
+}
+
+// EnqueueIn adds a job that becomes ready after delay
+func (s *Service) EnqueueIn(ctx context.Context, jobType string, payload interface{}, delay time.Duration) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	job := Job{
+		ID:          uuid.NewString(),
+		Type:        jobType,
+		Payload:     raw,
+		MaxAttempts: s.conf.GetInt(utils.QueueMaxAttempts),
+		EnqueuedAt:  time.Now(),
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("HSET", jobsKey, job.ID, data)
+	if delay > 0 {
+		conn.Send("ZADD", delayedKey, toScore(time.Now().Add(delay)), job.ID)
+	} else {
+		conn.Send("LPUSH", readyKey, job.ID)
+	}
+	if _, err := conn.Do("EXEC"); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// DeadLetters returns the most recent jobs that ran out of attempts
+func (s *Service) DeadLetters(ctx context.Context, limit int) ([]Job, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	items, err := redis.ByteSlices(conn.Do("LRANGE", deadKey, 0, limit-1))
+	if err != nil {
+		return nil, err
+	}
+	jobs := []Job{}
+	for _, item := range items {
+		job := Job{}
+		if err := json.Unmarshal(item, &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// reserve hands the next ready job to the caller until the visibility timeout passes.
+// It returns nil when the queue is empty.
+func (s *Service) reserve(ctx context.Context) (*Job, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.visibilityTimeout())
+	id, err := redis.String(reserveScript.Do(conn, readyKey, inflightKey, toScore(deadline)))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := redis.Bytes(conn.Do("HGET", jobsKey, id))
+	if err == redis.ErrNil {
+		// acknowledged by a worker whose visibility timeout had expired
+		_, err = conn.Do("ZREM", inflightKey, id)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	if job.LastAttempt() {
+		// the worker running its last attempt died or exceeded the visibility timeout
+		return nil, s.fail(ctx, job, ErrVisibilityTimeout)
+	}
+	job.Attempts++
+	data, err = json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Do("HSET", jobsKey, id, data)
+	return job, err
+}
+
+func (s *Service) ack(ctx context.Context, job *Job) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = ackScript.Do(conn, inflightKey, jobsKey, job.ID)
+	return err
+}
+
+// fail retries a job with exponential backoff, or dead-letters it on its last attempt
+func (s *Service) fail(ctx context.Context, job *Job, cause error) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	job.LastError = cause.Error()
+	if job.LastAttempt() || errors.Is(cause, ErrUnknownJobType) {
+		job.FailedAt = time.Now()
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		_, err = deadScript.Do(conn, inflightKey, jobsKey, deadKey, job.ID, data, maxDeadLetters-1)
+		return err
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	runAt := time.Now().Add(s.backoff(job.Attempts))
+	_, err = retryScript.Do(conn, inflightKey, jobsKey, delayedKey, job.ID, toScore(runAt), data)
+	return err
+}
+
+// promote moves due retries, and jobs whose worker exceeded the visibility timeout, back to ready
+func (s *Service) promote(ctx context.Context) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	now := toScore(time.Now())
+	for _, key := range []string{delayedKey, inflightKey} {
+		moved, err := redis.Int(promoteScript.Do(conn, key, readyKey, now, promoteBatch))
+		if err != nil {
+			return err
+		}
+		if moved > 0 && key == inflightKey {
+			s.log.WithField("jobs", moved).Warn("queue: visibility timeout expired, jobs requeued")
+		}
+	}
+	return nil
+}
+
+// claimSchedule reports whether this worker should enqueue the scheduled job for the current interval
+func (s *Service) claimSchedule(ctx context.Context, sc schedule) (bool, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	_, err = redis.String(conn.Do("SET", scheduleKey+sc.jobType, time.Now().Unix(), "NX", "PX", sc.every.Milliseconds()))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Service) backoff(attempt int) time.Duration {
+	d := s.conf.GetDuration(utils.QueueBackoff)
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	// jitter so that jobs failing together do not retry together
+	return d + time.Duration(rand.Int63n(int64(d)/10+1))
+}
+
+func (s *Service) visibilityTimeout() time.Duration {
+	return s.conf.GetDuration(utils.QueueVisibilityTimeout)
+}
+
+func toScore(t time.Time) string {
+	return fmt.Sprint(t.UnixMilli())
+}
+
+// Stats are the number of jobs in each state
+type Stats struct {
+	Ready    int `json:"ready"`
+	Inflight int `json:"inflight"`
+	Delayed  int `json:"delayed"`
+	Dead     int `json:"dead"`
+}
+
+// Stats returns the current queue sizes
+func (s *Service) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{}
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return stats, err
+	}
+	defer conn.Close()
+	conn.Send("MULTI")
+	conn.Send("LLEN", readyKey)
+	conn.Send("ZCARD", inflightKey)
+	conn.Send("ZCARD", delayedKey)
+	conn.Send("LLEN", deadKey)
+	res, err := redis.Ints(conn.Do("EXEC"))
+	if err != nil {
+		return stats, err
+	}
+	stats.Ready, stats.Inflight, stats.Delayed, stats.Dead = res[0], res[1], res[2], res[3]
+	return stats, nil
+}
@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+// StartWorker consumes the queue with `queue_concurrency` goroutines and runs
+// the registered schedules for as long as the app is running.
+func StartWorker(lc fx.Lifecycle, s *Service) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			concurrency := s.conf.GetInt(utils.QueueConcurrency)
+			if concurrency <= 0 {
+				concurrency = 1
+			}
+			wg.Add(2 + len(s.schedules) + concurrency)
+			go func() { defer wg.Done(); s.promoteLoop(ctx) }()
+			go func() { defer wg.Done(); s.reportLoop(ctx) }()
+			for _, sc := range s.schedules {
+				go func(sc schedule) { defer wg.Done(); s.scheduleLoop(ctx, sc) }(sc)
+			}
+			for i := 0; i < concurrency; i++ {
+				go func() { defer wg.Done(); s.consumeLoop(ctx) }()
+			}
+			s.log.WithFields(logrus.Fields{
+				"concurrency": concurrency,
+				"handlers":    len(s.handlers),
+				"schedules":   len(s.schedules),
+			}).Info("queue worker started")
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			// running jobs get until the fx stop timeout to finish,
+			// unfinished ones are redelivered once their visibility timeout expires
+			cancel()
+			done := make(chan struct{})
+			go func() { wg.Wait(); close(done) }()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+func (s *Service) consumeLoop(ctx context.Context) {
+	poll := s.conf.GetDuration(utils.QueuePollInterval)
+	for ctx.Err() == nil {
+		job, err := s.reserve(ctx)
+		if err != nil {
+			s.log.Error("queue: reserve failed: " + err.Error())
+		}
+		if job == nil {
+			sleep(ctx, poll)
+			continue
+		}
+		s.process(job)
+	}
+}
+
+// process runs the handler of a reserved job and acks or fails it.
+// Handlers get a context that is not cancelled on shutdown, only by the visibility timeout.
+// The job is settled with a context of its own, so a handler that ran out of time is still failed.
+func (s *Service) process(job *Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.visibilityTimeout())
+	defer cancel()
+	log := s.log.WithFields(logrus.Fields{"job_id": job.ID, "type": job.Type, "attempt": job.Attempts})
+
+	started := time.Now()
+	err := s.handle(ctx, job)
+	settleCtx, settleCancel := context.WithTimeout(context.Background(), settleTimeout)
+	defer settleCancel()
+	if err == nil {
+		if err := s.ack(settleCtx, job); err != nil {
+			log.Error("queue: ack failed: " + err.Error())
+		}
+		log.WithField("took", time.Since(started).String()).Info("queue: job done")
+		return
+	}
+	log.Warn("queue: job failed: " + err.Error())
+	if err := s.fail(settleCtx, job, err); err != nil {
+		log.Error("queue: fail failed: " + err.Error())
+	}
+}
+
+func (s *Service) handle(ctx context.Context, job *Job) (err error) {
+	fn, ok := s.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx, *job)
+}
+
+func (s *Service) promoteLoop(ctx context.Context) {
+	poll := s.conf.GetDuration(utils.QueuePollInterval)
+	for ctx.Err() == nil {
+		if err := s.promote(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error("queue: promote failed: " + err.Error())
+		}
+		sleep(ctx, poll)
+	}
+}
+
+func (s *Service) scheduleLoop(ctx context.Context, sc schedule) {
+	ticker := time.NewTicker(sc.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := s.claimSchedule(ctx, sc)
+			if err != nil {
+				s.log.WithField("type", sc.jobType).Error("queue: schedule failed: " + err.Error())
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if _, err := s.Enqueue(ctx, sc.jobType, nil); err != nil {
+				s.log.WithField("type", sc.jobType).Error("queue: schedule failed: " + err.Error())
+			}
+		}
+	}
+}
+
+// reportLoop periodically logs the queue sizes
+func (s *Service) reportLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := s.Stats(ctx)
+			if err != nil {
+				s.log.Error("queue: stats failed: " + err.Error())
+				continue
+			}
+			s.log.WithFields(logrus.Fields{
+				"ready":    stats.Ready,
+				"inflight": stats.Inflight,
+				"delayed":  stats.Delayed,
+				"dead":     stats.Dead,
+			}).Info("queue stats")
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
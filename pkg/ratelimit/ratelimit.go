@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the redis backed rate limiter
+var Module = fx.Options(
+	fx.Provide(
+		NewService,
+	),
+)
+
+// Kinds of subjects a limit is counted for
+const (
+	KindIP     = "ip"
+	KindUser   = "user"
+	KindAPIKey = "api_key"
+)
+
+type (
+	// Subject is who a request is counted against, e.g. {KindUser, "42"}
+	Subject struct {
+		Kind string
+		ID   string
+	}
+	// Rule allows Requests per Window to one subject kind of a named limit
+	Rule struct {
+		Name     string
+		Kind     string
+		Requests int
+		Window   time.Duration
+	}
+	// Result is the outcome of Allow, RetryAfter is set when the request was refused
+	Result struct {
+		Allowed    bool
+		RetryAfter time.Duration
+	}
+)
+
+// ParseRules reads space separated rules of the form `<name>.<kind>=<requests>/<window>`,
+// e.g. `chat.user=20/1m chat.ip=60/1m`
+func ParseRules(s string) ([]Rule, error) {
+	rules := []Rule{}
+	for _, field := range strings.Fields(s) {
+		target, limit, ok := strings.Cut(field, "=")
+		name, kind, ok2 := strings.Cut(target, ".")
+		requests, window, ok3 := strings.Cut(limit, "/")
+		if !ok || !ok2 || !ok3 {
+			return nil, fmt.Errorf("rate limit %q: want <name>.<kind>=<requests>/<window>", field)
+		}
+		if kind != KindIP && kind != KindUser && kind != KindAPIKey {
+			return nil, fmt.Errorf("rate limit %q: kind must be ip, user or api_key", field)
+		}
+		rule := Rule{Name: name, Kind: kind}
+		var err error
+		if rule.Requests, err = strconv.Atoi(requests); err != nil || rule.Requests <= 0 {
+			return nil, fmt.Errorf("rate limit %q: requests must be a positive number", field)
+		}
+		if rule.Window, err = time.ParseDuration(window); err != nil || rule.Window < time.Millisecond {
+			return nil, fmt.Errorf("rate limit %q: invalid window", field)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
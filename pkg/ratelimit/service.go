@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+const windowKey = "ratelimit:"
+
+// slideScript counts a request in sliding windows, one sorted set of request times per key.
+// ARGV holds now and a unique member followed by the limit and window of each key, all in ms.
+// Nothing is counted unless every window has room, it then returns 0, otherwise the ms until it has.
+var slideScript = redis.NewScript(-1, `
+local now = tonumber(ARGV[1])
+local wait = 0
+for i, key in ipairs(KEYS) do
+  local limit = tonumber(ARGV[1 + i * 2])
+  local window = tonumber(ARGV[2 + i * 2])
+  redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+  local count = redis.call('ZCARD', key)
+  if count >= limit then
+    local freed = redis.call('ZRANGE', key, count - limit, count - limit, 'WITHSCORES')
+    wait = math.max(wait, tonumber(freed[2]) + window - now)
+  end
+end
+if wait > 0 then return wait end
+for i, key in ipairs(KEYS) do
+  redis.call('ZADD', key, now, ARGV[2])
+  redis.call('PEXPIRE', key, ARGV[2 + i * 2])
+end
+return 0`)
+
+// NewServiceIn is function param struct of func `NewService`
+type NewServiceIn struct {
+	fx.In
+
+	Conf *viper.Viper
+	Log  *logrus.Logger
+	Pool *redis.Pool `name:"redisWorker"`
+}
+
+type Service struct {
+	log   *logrus.Logger
+	pool  *redis.Pool
+	rules map[string][]Rule
+}
+
+// NewService returns a rate limiter service object, it fails on invalid rate_limits.
+func NewService(i NewServiceIn) (*Service, error) {
+	rules, err := ParseRules(i.Conf.GetString(utils.RateLimits))
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		log:   i.Log,
+		pool:  i.Pool,
+		rules: map[string][]Rule{},
+	}
+	for _, rule := range rules {
+		s.rules[rule.Name] = append(s.rules[rule.Name], rule)
+	}
+	return s, nil
+}
+
+// Allow counts a request of the named limit against every subject that has a rule.
+// The limiter fails open, requests are allowed when redis can not be reached.
+func (s *Service) Allow(ctx context.Context, name string, subjects ...Subject) Result {
+	keys := []interface{}{}
+	limits := []interface{}{}
+	for _, rule := range s.rules[name] {
+		for _, subject := range subjects {
+			if subject.Kind != rule.Kind || subject.ID == "" {
+				continue
+			}
+			keys = append(keys, windowKey+name+":"+rule.Window.String()+":"+subject.Kind+":"+subject.ID)
+			limits = append(limits, rule.Requests, rule.Window.Milliseconds())
+		}
+	}
+	if len(keys) == 0 {
+		return Result{Allowed: true}
+	}
+	args := append([]interface{}{len(keys)}, keys...)
+	args = append(args, time.Now().UnixMilli(), uuid.NewString())
+	args = append(args, limits...)
+
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		s.log.WithField("limit", name).Warn("rate limit: " + err.Error())
+		return Result{Allowed: true}
+	}
+	defer conn.Close()
+	wait, err := redis.Int64(slideScript.Do(conn, args...))
+	if err != nil {
+		s.log.WithField("limit", name).Warn("rate limit: " + err.Error())
+		return Result{Allowed: true}
+	}
+	if wait > 0 {
+		return Result{RetryAfter: time.Duration(wait) * time.Millisecond}
+	}
+	return Result{Allowed: true}
+}
@@ -0,0 +1,128 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	hasPermission(context.Context, int, string) (bool, error)
+	retrieveRoles(context.Context) ([]Role, error)
+	retrieveUserRoles(context.Context, int) ([]Role, error)
+	fetchRole(context.Context, string) (*Role, error)
+	upsertRole(context.Context, *Role) error
+	assignRole(context.Context, *UserRole) error
+	unassignRole(context.Context, int, int) error
+	countUsersWithRole(context.Context, int) (int, error)
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+func (r *PGRepo) hasPermission(ctx context.Context, userID int, permission string) (bool, error) {
+	return r.db.ModelContext(ctx, (*UserRole)(nil)).
+		Join("JOIN roles AS r ON r.id = user_role.role_id").
+		Where("user_role.user_id = ?", userID).
+		Where("? = ANY(r.permissions)", permission).
+		Exists()
+}
+
+func (r *PGRepo) retrieveRoles(ctx context.Context) ([]Role, error) {
+	roles := []Role{}
+	err := r.db.ModelContext(ctx, &roles).Order("name").Select()
+	return roles, err
+}
+
+func (r *PGRepo) retrieveUserRoles(ctx context.Context, userID int) ([]Role, error) {
+	roles := []Role{}
+	err := r.db.ModelContext(ctx, &roles).
+		Join("JOIN user_roles AS ur ON ur.role_id = role.id").
+		Where("ur.user_id = ?", userID).
+		Order("name").
+		Select()
+	return roles, err
+}
+
+func (r *PGRepo) fetchRole(ctx context.Context, name string) (*Role, error) {
+	role := &Role{}
+	err := r.db.ModelContext(ctx, role).Where("name = ?", name).Select()
+	return role, err
+}
+
+func (r *PGRepo) upsertRole(ctx context.Context, role *Role) error {
+	_, err := r.db.ModelContext(ctx, role).
+		OnConflict("(name) DO UPDATE").
+		Set("description = EXCLUDED.description").
+		Set("permissions = EXCLUDED.permissions").
+		Returning("id, created_at").
+		Insert()
+	return err
+}
+
+func (r *PGRepo) assignRole(ctx context.Context, userRole *UserRole) error {
+	_, err := r.db.ModelContext(ctx, userRole).
+		OnConflict("DO NOTHING").
+		Insert()
+	return err
+}
+
+func (r *PGRepo) unassignRole(ctx context.Context, userID, roleID int) error {
+	res, err := r.db.ModelContext(ctx, (*UserRole)(nil)).
+		Where("user_id = ?", userID).
+		Where("role_id = ?", roleID).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func (r *PGRepo) countUsersWithRole(ctx context.Context, roleID int) (int, error) {
+	return r.db.ModelContext(ctx, (*UserRole)(nil)).
+		Where("role_id = ?", roleID).
+		Count()
+}
+
+// SeedAdminRole creates the admin role, or gives it permissions added since it was created
+func SeedAdminRole(ctx context.Context, db *pg.DB, permissions []string) error {
+	_, err := db.ModelContext(ctx, &Role{
+		Name:        AdminRole,
+		Description: "every permission",
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+	}).
+		OnConflict("(name) DO UPDATE").
+		Set("permissions = EXCLUDED.permissions").
+		Insert()
+	return err
+}
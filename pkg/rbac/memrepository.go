@@ -0,0 +1,122 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// MemRepo keeps roles in memory, it backs tests that run without postgres
+type MemRepo struct {
+	mu        sync.Mutex
+	roles     []Role
+	userRoles []UserRole
+}
+
+// NewMemRepository returns an empty in-memory persistence layer
+func NewMemRepository() Repository {
+	return &MemRepo{}
+}
+
+func (r *MemRepo) hasPermission(ctx context.Context, userID int, permission string) (bool, error) {
+	roles, err := r.retrieveUserRoles(ctx, userID)
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			if p == permission {
+				return true, nil
+			}
+		}
+	}
+	return false, err
+}
+
+func (r *MemRepo) retrieveRoles(ctx context.Context) ([]Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	roles := append([]Role{}, r.roles...)
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *MemRepo) retrieveUserRoles(ctx context.Context, userID int) ([]Role, error) {
+	all, _ := r.retrieveRoles(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	roles := []Role{}
+	for _, role := range all {
+		for _, ur := range r.userRoles {
+			if ur.UserID == userID && ur.RoleID == role.ID {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles, nil
+}
+
+func (r *MemRepo) fetchRole(ctx context.Context, name string) (*Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, role := range r.roles {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+	return &Role{}, pg.ErrNoRows
+}
+
+func (r *MemRepo) upsertRole(ctx context.Context, role *Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.roles {
+		if existing.Name == role.Name {
+			r.roles[i].Description, r.roles[i].Permissions = role.Description, role.Permissions
+			role.ID, role.CreatedAt = existing.ID, existing.CreatedAt
+			return nil
+		}
+	}
+	role.ID = len(r.roles) + 1
+	if role.CreatedAt.IsZero() {
+		role.CreatedAt = time.Now()
+	}
+	r.roles = append(r.roles, *role)
+	return nil
+}
+
+func (r *MemRepo) assignRole(ctx context.Context, userRole *UserRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ur := range r.userRoles {
+		if ur.UserID == userRole.UserID && ur.RoleID == userRole.RoleID {
+			return nil
+		}
+	}
+	r.userRoles = append(r.userRoles, *userRole)
+	return nil
+}
+
+func (r *MemRepo) unassignRole(ctx context.Context, userID, roleID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, ur := range r.userRoles {
+		if ur.UserID == userID && ur.RoleID == roleID {
+			r.userRoles = append(r.userRoles[:i], r.userRoles[i+1:]...)
+			return nil
+		}
+	}
+	return pg.ErrNoRows
+}
+
+func (r *MemRepo) countUsersWithRole(ctx context.Context, roleID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, ur := range r.userRoles {
+		if ur.RoleID == roleID {
+			n++
+		}
+	}
+	return n, nil
+}
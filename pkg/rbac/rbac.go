@@ -0,0 +1,37 @@
+package rbac
+
+import (
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the role based access control service
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+// AdminRole is kept in sync with every permission when the schema is created
+const AdminRole = "admin"
+
+type (
+	// Role is a named set of permissions
+	Role struct {
+		tableName   struct{}  `pg:"roles,discard_unknown_columns"`
+		ID          int       `json:"id" pg:"id,pk"`
+		Name        string    `json:"name" pg:"name,unique"`
+		Description string    `json:"description,omitempty" pg:"description"`
+		Permissions []string  `json:"permissions" pg:"permissions,array"`
+		CreatedAt   time.Time `json:"created_at" pg:"created_at"`
+	}
+	// UserRole assigns a role to a user
+	UserRole struct {
+		tableName struct{}  `pg:"user_roles,discard_unknown_columns"`
+		UserID    int       `json:"user_id" pg:"user_id,pk,type:bigint"`
+		RoleID    int       `json:"role_id" pg:"role_id,pk,type:bigint"`
+		CreatedAt time.Time `json:"created_at" pg:"created_at"`
+	}
+)
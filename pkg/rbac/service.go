@@ -0,0 +1,152 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/utils/types"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidPermission = errors.New("unknown permission")
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrAdminExists       = errors.New("an admin already exists, assign roles through the admin api")
+	ErrAdminRoleReadonly = errors.New("the admin role always has every permission")
+	ErrEscalation        = errors.New("the role grants permissions you do not have")
+)
+
+type Service struct {
+	log  *logrus.Logger
+	Repo Repository
+}
+
+// NewService returns a role based access control service object.
+func NewService(log *logrus.Logger, Repo Repository) *Service {
+	return &Service{
+		log:  log,
+		Repo: Repo,
+	}
+}
+
+// HasPermission reports whether one of the user's roles grants the permission
+func (s *Service) HasPermission(ctx context.Context, userID int, permission types.Permission) (bool, error) {
+	return s.Repo.hasPermission(ctx, userID, string(permission))
+}
+
+// Roles returns every role
+func (s *Service) Roles(ctx context.Context) ([]Role, error) {
+	return s.Repo.retrieveRoles(ctx)
+}
+
+// UserRoles returns the roles assigned to the user
+func (s *Service) UserRoles(ctx context.Context, userID int) ([]Role, error) {
+	return s.Repo.retrieveUserRoles(ctx, userID)
+}
+
+// SaveRole creates a role or replaces the permissions of an existing one, the actor
+// can only grant permissions their own roles have
+func (s *Service) SaveRole(ctx context.Context, actorID int, name, description string, permissions []string) (*Role, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == AdminRole {
+		return nil, ErrAdminRoleReadonly
+	}
+	for _, permission := range permissions {
+		if !types.Permission(permission).Valid() {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPermission, permission)
+		}
+	}
+	if err := s.grantable(ctx, actorID, permissions); err != nil {
+		return nil, err
+	}
+	role := &Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+	}
+	return role, s.Repo.upsertRole(ctx, role)
+}
+
+// Assign gives the role to the user, assigning it twice does nothing. The actor can only
+// assign roles whose permissions their own roles have, so that nobody makes themselves an admin.
+func (s *Service) Assign(ctx context.Context, actorID, userID int, roleName string) error {
+	role, err := s.role(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	if err := s.grantable(ctx, actorID, role.Permissions); err != nil {
+		return err
+	}
+	return s.Repo.assignRole(ctx, &UserRole{UserID: userID, RoleID: role.ID, CreatedAt: time.Now()})
+}
+
+// Unassign takes the role away from the user, it returns pg.ErrNoRows when the user does not have it.
+// Like Assign, the actor's roles must have the role's permissions.
+func (s *Service) Unassign(ctx context.Context, actorID, userID int, roleName string) error {
+	role, err := s.role(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	if err := s.grantable(ctx, actorID, role.Permissions); err != nil {
+		return err
+	}
+	return s.Repo.unassignRole(ctx, userID, role.ID)
+}
+
+// BootstrapAdmin makes the user the first admin, it fails once anybody has the admin role
+func (s *Service) BootstrapAdmin(ctx context.Context, userID int) error {
+	role, err := s.role(ctx, AdminRole)
+	if err != nil {
+		return err
+	}
+	admins, err := s.Repo.countUsersWithRole(ctx, role.ID)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return ErrAdminExists
+	}
+	return s.Repo.assignRole(ctx, &UserRole{UserID: userID, RoleID: role.ID, CreatedAt: time.Now()})
+}
+
+// grantable returns ErrEscalation unless the actor's roles have every permission
+func (s *Service) grantable(ctx context.Context, actorID int, permissions []string) error {
+	roles, err := s.Repo.retrieveUserRoles(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	held := map[string]bool{}
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			held[permission] = true
+		}
+	}
+	for _, permission := range permissions {
+		if !held[permission] {
+			return fmt.Errorf("%w: %s", ErrEscalation, permission)
+		}
+	}
+	return nil
+}
+
+func (s *Service) role(ctx context.Context, name string) (*Role, error) {
+	role, err := s.Repo.fetchRole(ctx, strings.ToLower(strings.TrimSpace(name)))
+	if err == pg.ErrNoRows {
+		return nil, ErrRoleNotFound
+	}
+	return role, err
+}
+
+// PermissionNames returns every permission as strings
+func PermissionNames() []string {
+	names := []string{}
+	for _, permission := range types.Permissions {
+		names = append(names, string(permission))
+	}
+	return names
+}
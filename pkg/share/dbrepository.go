@@ -0,0 +1,101 @@
+package share
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	createLink(context.Context, *Link) error
+	fetchLinkByHash(context.Context, string) (*Link, error)
+	retrieveLinks(context.Context, int) ([]Link, error)
+	revokeLink(context.Context, int, int) error
+	countView(context.Context, int) (bool, error)
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+func (r *PGRepo) createLink(ctx context.Context, link *Link) error {
+	_, err := r.db.ModelContext(ctx, link).Insert()
+	return err
+}
+
+// fetchLinkByHash returns a link that is neither revoked nor expired
+func (r *PGRepo) fetchLinkByHash(ctx context.Context, hash string) (*Link, error) {
+	link := &Link{}
+	err := r.db.ModelContext(ctx, link).
+		Where("token_hash = ?", hash).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Select()
+	return link, err
+}
+
+// retrieveLinks returns the links of the user that can still be opened
+func (r *PGRepo) retrieveLinks(ctx context.Context, userID int) ([]Link, error) {
+	links := []Link{}
+	err := r.db.ModelContext(ctx, &links).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Order("id DESC").
+		Select()
+	return links, err
+}
+
+func (r *PGRepo) revokeLink(ctx context.Context, userID, id int) error {
+	res, err := r.db.ModelContext(ctx, (*Link)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+// countView adds a view unless the link ran out of views, it reports whether the view was counted
+func (r *PGRepo) countView(ctx context.Context, id int) (bool, error) {
+	res, err := r.db.ModelContext(ctx, (*Link)(nil)).
+		Set("views = views + 1").
+		Where("id = ?", id).
+		Where("max_views IS NULL OR views < max_views").
+		Update()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
@@ -0,0 +1,213 @@
+package share
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/pkg/user"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// albumPageSize is the page size used to collect the photos of a shared album
+const albumPageSize = 100
+
+var (
+	// ErrNotFound hides whether a link never existed, expired, was revoked or ran out of views
+	ErrNotFound         = errors.New("share link not found or expired")
+	ErrPasswordRequired = errors.New("this link is password protected")
+	ErrWrongPassword    = errors.New("wrong password")
+	ErrInvalidTarget    = errors.New("share either a photo or an album")
+	ErrExpiryTooLong    = errors.New("share links can not live that long")
+)
+
+type Service struct {
+	conf    *viper.Viper
+	log     *logrus.Logger
+	Repo    Repository
+	user    *user.Service
+	storage *storage.Service
+}
+
+// NewService returns a share link service object.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, user *user.Service, storage *storage.Service) *Service {
+	return &Service{
+		conf:    conf,
+		log:     log,
+		Repo:    Repo,
+		user:    user,
+		storage: storage,
+	}
+}
+
+// Create makes a link to a photo or album of the owner. The URL of the returned link is only shown once.
+func (s *Service) Create(ctx context.Context, owner *user.User, req Request) (*Link, error) {
+	req.Album = strings.TrimSpace(req.Album)
+	if (req.PhotoID == 0) == (req.Album == "") {
+		return nil, ErrInvalidTarget
+	}
+	if req.ExpiresIn <= 0 {
+		req.ExpiresIn = s.conf.GetDuration(utils.ShareLinkDefaultExpiry)
+	}
+	if req.ExpiresIn > s.conf.GetDuration(utils.ShareLinkMaxExpiry) {
+		return nil, ErrExpiryTooLong
+	}
+	if req.MaxViews < 0 {
+		req.MaxViews = 0
+	}
+	if req.PhotoID != 0 {
+		photo, err := s.user.FetchPhoto(ctx, req.PhotoID)
+		if err != nil {
+			return nil, err
+		}
+		// only the owner may make a photo public, grants do not extend to it
+		if photo.UserID != owner.ID || !photo.IsActive {
+			return nil, user.ErrForbidden
+		}
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+	link := &Link{
+		UserID:    owner.ID,
+		TokenHash: hash(token),
+		PhotoID:   req.PhotoID,
+		Album:     req.Album,
+		MaxViews:  req.MaxViews,
+		ExpiresAt: now.Add(req.ExpiresIn),
+		CreatedAt: now,
+	}
+	if req.Password != "" {
+		passwordHash, err := user.HashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = passwordHash
+	}
+	if err := s.Repo.createLink(ctx, link); err != nil {
+		return nil, err
+	}
+	link.HasPassword = link.PasswordHash != ""
+	link.URL = strings.TrimSuffix(s.conf.GetString(utils.PublicURL), "/") + "/s/" + token
+	return link, nil
+}
+
+// List returns the links of the user that can still be opened
+func (s *Service) List(ctx context.Context, userID int) ([]Link, error) {
+	links, err := s.Repo.retrieveLinks(ctx, userID)
+	for i := range links {
+		links[i].HasPassword = links[i].PasswordHash != ""
+	}
+	return links, err
+}
+
+// Revoke disables a link of the user, it returns pg.ErrNoRows when the user has no such link
+func (s *Service) Revoke(ctx context.Context, userID, id int) error {
+	return s.Repo.revokeLink(ctx, userID, id)
+}
+
+// Open checks the token and password, counts a view and returns the shared photos
+// with presigned URLs. Photos trashed since the link was made are left out.
+func (s *Service) Open(ctx context.Context, token, password string) (*Content, error) {
+	link, err := s.Repo.fetchLinkByHash(ctx, hash(token))
+	if err == pg.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if link.PasswordHash != "" {
+		if password == "" {
+			return nil, ErrPasswordRequired
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			return nil, ErrWrongPassword
+		}
+	}
+
+	photos, err := s.photos(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	if len(photos) == 0 {
+		return nil, ErrNotFound
+	}
+	ok, err := s.Repo.countView(ctx, link.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	expiry := s.conf.GetDuration(utils.SharePresignExpiry)
+	if remaining := time.Until(link.ExpiresAt); remaining < expiry {
+		expiry = remaining
+	}
+	content := &Content{Album: link.Album, Items: []Item{}}
+	for _, photo := range photos {
+		item := Item{ID: photo.ID, ContentType: photo.ContentType}
+		if item.Url, err = s.storage.PresignGet(s.storage.KeyFromURL(photo.Url), expiry); err != nil {
+			return nil, err
+		}
+		item.ThumbnailUrl = item.Url
+		if photo.ThumbnailUrl != "" {
+			if item.ThumbnailUrl, err = s.storage.PresignGet(s.storage.KeyFromURL(photo.ThumbnailUrl), expiry); err != nil {
+				return nil, err
+			}
+		}
+		content.Items = append(content.Items, item)
+	}
+	return content, nil
+}
+
+// photos returns the active photos a link points to
+func (s *Service) photos(ctx context.Context, link *Link) ([]user.UserImages, error) {
+	if link.PhotoID != 0 {
+		photo, err := s.user.FetchPhoto(ctx, link.PhotoID)
+		if err == pg.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !photo.IsActive || photo.UserID != link.UserID {
+			return nil, nil
+		}
+		return []user.UserImages{*photo}, nil
+	}
+
+	photos := []user.UserImages{}
+	filter := user.PhotoFilter{Album: link.Album, Sort: "created_at", Limit: albumPageSize}
+	for {
+		page, err := s.user.ListPhotos(ctx, link.UserID, filter)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, page.Photos...)
+		if !page.HasMore {
+			return photos, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// hash is enough for link tokens, they are random and long unlike passwords
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
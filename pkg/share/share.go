@@ -0,0 +1,62 @@
+package share
+
+import (
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the share link service and registers its chat tool
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+	fx.Invoke(
+		RegisterTools,
+	),
+)
+
+type (
+	// Link gives anyone holding its token access to a photo or an album, only the token hash is stored
+	Link struct {
+		tableName    struct{}  `pg:"share_links,discard_unknown_columns"`
+		ID           int       `json:"id" pg:"id,pk"`
+		UserID       int       `json:"-" pg:"user_id"`
+		TokenHash    string    `json:"-" pg:"token_hash,unique"`
+		PhotoID      int       `json:"photo_id,omitempty" pg:"photo_id"`
+		Album        string    `json:"album,omitempty" pg:"album"`
+		PasswordHash string    `json:"-" pg:"password_hash"`
+		MaxViews     int       `json:"max_views,omitempty" pg:"max_views"`
+		Views        int       `json:"views" pg:"views,use_zero"`
+		ExpiresAt    time.Time `json:"expires_at" pg:"expires_at"`
+		RevokedAt    time.Time `json:"revoked_at,omitempty" pg:"revoked_at"`
+		CreatedAt    time.Time `json:"created_at" pg:"created_at"`
+
+		// HasPassword is set from PasswordHash
+		HasPassword bool `json:"has_password" pg:"-"`
+		// URL is only known right after the link is created
+		URL string `json:"url,omitempty" pg:"-"`
+	}
+	// Request describes a new link, exactly one of PhotoID and Album must be set
+	Request struct {
+		PhotoID   int
+		Album     string
+		Password  string
+		ExpiresIn time.Duration
+		// MaxViews of 0 allows unlimited views until the link expires
+		MaxViews int
+	}
+	// Item is a shared photo with presigned URLs valid for a short time
+	Item struct {
+		ID           int
+		Url          string
+		ThumbnailUrl string
+		ContentType  string
+	}
+	// Content is what a link opens to
+	Content struct {
+		Album string
+		Items []Item
+	}
+)
@@ -0,0 +1,67 @@
+package share
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/user"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+type shareLinkArgs struct {
+	PhotoID  int    `json:"photo_id"`
+	Album    string `json:"album"`
+	Hours    int    `json:"hours"`
+	MaxViews int    `json:"max_views"`
+	Password string `json:"password"`
+}
+
+// RegisterTools adds the share link chat tool to the bot
+func RegisterTools(userService *user.Service, s *Service) {
+	userService.RegisterTool(openai.FunctionDefinition{
+		Name:        "CreateShareLink",
+		Description: "creates a link that lets anyone without an account see one photo or one album of the user until it expires. use it when the user asks to share photos with someone, e.g. share my beach album for 2 days",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"photo_id":  {Type: jsonschema.Integer, Description: "the photo id as listed by FetchPhotos e.g., 42"},
+				"album":     {Type: jsonschema.String, Description: "the album to share e.g., beach"},
+				"hours":     {Type: jsonschema.Integer, Description: "how many hours the link works e.g., 48 for 2 days, leave it out for the default"},
+				"max_views": {Type: jsonschema.Integer, Description: "how many times the link can be opened, leave it out for no limit"},
+				"password":  {Type: jsonschema.String, Description: "a password the viewer must type, only when the user asks for one"},
+			},
+		},
+	}, apikey.ScopePhotosWrite, s.createShareLinkTool)
+}
+
+func (s *Service) createShareLinkTool(ctx context.Context, principal *user.User, raw json.RawMessage) (string, error) {
+	args := shareLinkArgs{}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	link, err := s.Create(ctx, principal, Request{
+		PhotoID:   args.PhotoID,
+		Album:     args.Album,
+		Password:  args.Password,
+		ExpiresIn: time.Duration(args.Hours) * time.Hour,
+		MaxViews:  args.MaxViews,
+	})
+	switch {
+	case err == ErrInvalidTarget:
+		return "ask the user whether to share one photo or an album", nil
+	case err == ErrExpiryTooLong:
+		return "the link can not last that long, ask for a shorter time", nil
+	case err == user.ErrWeakPassword:
+		return "the password must be at least 8 characters, ask for another one", nil
+	case err == pg.ErrNoRows:
+		return "photo not found ask to check the photo id", nil
+	case err != nil:
+		return "", err
+	}
+	return fmt.Sprintf("share link #%d created, give the user this url: %s, it expires %s", link.ID, link.URL, link.ExpiresAt.Format(time.RFC1123)), nil
+}
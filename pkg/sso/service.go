@@ -0,0 +1,219 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"uber_fx_init_folder_structure/pkg/user"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gomodule/redigo/redis"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"golang.org/x/oauth2"
+)
+
+const (
+	stateKey = "sso:state:"
+	// StateTTL is how long a user has to sign in at the issuer
+	StateTTL = 10 * time.Minute
+	// StateCookie binds the state to the browser that started the sign in
+	StateCookie = "sso_state"
+)
+
+var (
+	ErrDisabled     = errors.New("single sign-on is not configured")
+	ErrInvalidState = errors.New("unknown or expired sign in attempt, please start again")
+	ErrNoIDToken    = errors.New("issuer did not return an id token")
+)
+
+// takeScript reads and deletes the pending sign in so that a state can only be used once
+var takeScript = redis.NewScript(1, `
+local v = redis.call('GET', KEYS[1])
+if v then redis.call('DEL', KEYS[1]) end
+return v`)
+
+// NewServiceIn is function param struct of func `NewService`
+type NewServiceIn struct {
+	fx.In
+
+	Conf *viper.Viper
+	Log  *logrus.Logger
+	Pool *redis.Pool `name:"redisWorker"`
+	User *user.Service
+}
+
+type Service struct {
+	conf *viper.Viper
+	log  *logrus.Logger
+	pool *redis.Pool
+	user *user.Service
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewService returns a single sign-on service object.
+// Discovery runs on the first sign in, so the server starts while the issuer is unreachable.
+func NewService(i NewServiceIn) *Service {
+	return &Service{
+		conf: i.Conf,
+		log:  i.Log,
+		pool: i.Pool,
+		user: i.User,
+	}
+}
+
+// Enabled reports whether an issuer is configured
+func (s *Service) Enabled() bool {
+	return s.conf.GetString(utils.OIDCIssuer) != ""
+}
+
+// AuthCodeURL starts an authorization code flow with PKCE and returns the issuer URL to redirect to
+// and the state, which the callback must present in the StateCookie. loginHint is passed on to the
+// issuer and may be empty, linkUserID is the signed in user to link the identity to, zero to sign in.
+func (s *Service) AuthCodeURL(ctx context.Context, loginHint string, linkUserID int) (string, string, error) {
+	config, _, err := s.oauth2Config(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	p := pending{Verifier: oauth2.GenerateVerifier(), Nonce: nonce, LinkUserID: linkUserID}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", "", err
+	}
+
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+	if _, err := conn.Do("SET", stateKey+state, data, "EX", int(StateTTL.Seconds())); err != nil {
+		return "", "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(p.Verifier), oidc.Nonce(nonce)}
+	if loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+	return config.AuthCodeURL(state, opts...), state, nil
+}
+
+// SecureCookie reports whether the callback is served over https, the state cookie is then only sent over https
+func (s *Service) SecureCookie() bool {
+	return strings.HasPrefix(s.conf.GetString(utils.OIDCRedirectURL), "https://")
+}
+
+// Exchange completes the flow started by AuthCodeURL. The ID token is verified against the issuer's keys
+// and its user is returned, created on the first sign in or linked to the user who started the flow.
+func (s *Service) Exchange(ctx context.Context, state, code string) (*user.User, error) {
+	config, provider, err := s.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.takePending(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(p.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("sso: code exchange: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrNoIDToken
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("sso: %w", err)
+	}
+	if idToken.Nonce != p.Nonce {
+		return nil, errors.New("sso: id token nonce does not match")
+	}
+	c := claims{}
+	if err := idToken.Claims(&c); err != nil {
+		return nil, err
+	}
+	identity := user.SSOIdentity{
+		Issuer:            idToken.Issuer,
+		Subject:           idToken.Subject,
+		Email:             c.Email,
+		EmailVerified:     c.EmailVerified,
+		PreferredUsername: c.PreferredUsername,
+		FirstName:         c.GivenName,
+		LastName:          c.FamilyName,
+	}
+	if p.LinkUserID != 0 {
+		return s.user.LinkSSO(ctx, p.LinkUserID, identity)
+	}
+	return s.user.SignInSSO(ctx, identity)
+}
+
+func (s *Service) takePending(ctx context.Context, state string) (*pending, error) {
+	if state == "" {
+		return nil, ErrInvalidState
+	}
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	data, err := redis.Bytes(takeScript.Do(conn, stateKey+state))
+	if err == redis.ErrNil {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &pending{}
+	return p, json.Unmarshal(data, p)
+}
+
+// oauth2Config runs discovery once it succeeds and builds the client configuration
+func (s *Service) oauth2Config(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+	if !s.Enabled() {
+		return nil, nil, ErrDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider == nil {
+		// the provider keeps using its context to fetch keys, it must outlive the request
+		provider, err := oidc.NewProvider(context.Background(), s.conf.GetString(utils.OIDCIssuer))
+		if err != nil {
+			return nil, nil, fmt.Errorf("sso: discovery: %w", err)
+		}
+		s.provider = provider
+	}
+	return &oauth2.Config{
+		ClientID:     s.conf.GetString(utils.OIDCClientID),
+		ClientSecret: s.conf.GetString(utils.OIDCClientSecret),
+		RedirectURL:  s.conf.GetString(utils.OIDCRedirectURL),
+		Endpoint:     s.provider.Endpoint(),
+		Scopes:       strings.Fields(s.conf.GetString(utils.OIDCScopes)),
+	}, s.provider, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
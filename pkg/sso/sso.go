@@ -0,0 +1,30 @@
+package sso
+
+import (
+	"go.uber.org/fx"
+)
+
+// Module provides the OpenID Connect single sign-on service
+var Module = fx.Options(
+	fx.Provide(
+		NewService,
+	),
+)
+
+// claims are the ID token claims mapped to a user
+type claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+}
+
+// pending is what is kept between the redirect to the issuer and the callback
+type pending struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	// LinkUserID is the signed in user the identity is linked to, zero for a sign in
+	LinkUserID int `json:"link_user_id,omitempty"`
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+	awsSession "uber_fx_init_folder_structure/internal/mw/aws"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Object is a single entry of a bucket listing
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type Service struct {
+	conf   *viper.Viper
+	log    *logrus.Logger
+	sess   *session.Session
+	bucket string
+	region string
+}
+
+// NewService returns a storage service object.
+func NewService(conf *viper.Viper, log *logrus.Logger) *Service {
+	region := conf.GetString(utils.Region)
+	return &Service{
+		conf:   conf,
+		log:    log,
+		sess:   awsSession.ConnectAws(region, conf.GetString(utils.AccessKeyEnv), conf.GetString(utils.SecretAccessKey)),
+		bucket: conf.GetString(utils.BucketName),
+		region: region,
+	}
+}
+
+// URL returns the public URL under which an object key is stored
+func (s *Service) URL(key string) string {
+	return "https://" + s.bucket + "." + "s3-" + s.region + ".amazonaws.com/" + key
+}
+
+// KeyFromURL returns the object key of a URL built by URL
+func (s *Service) KeyFromURL(url string) string {
+	i := strings.Index(url, ".amazonaws.com/")
+	if i < 0 {
+		return url
+	}
+	return url[i+len(".amazonaws.com/"):]
+}
+
+// Delete permanently removes an object from the bucket.
+// Deleting a key that does not exist is not an error.
+func (s *Service) Delete(ctx context.Context, key string) error {
+	_, err := s3.New(s.sess).DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound") {
+		// the object is gone already
+		return nil
+	}
+	return err
+}
+
+// Open returns a reader streaming the content of an object.
+// The caller must close it.
+func (s *Service) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s3.New(s.sess).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Upload streams body into an object, the body does not need to be seekable
+func (s *Service) Upload(ctx context.Context, key string, body io.Reader, contentType string) error {
+	_, err := s3manager.NewUploader(s.sess).UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// PresignGet returns a URL that allows downloading a private object until it expires
+func (s *Service) PresignGet(key string, expiry time.Duration) (string, error) {
+	req, _ := s3.New(s.sess).GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(expiry)
+}
+
+// List returns every object whose key starts with prefix
+func (s *Service) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	err := s3.New(s.sess).ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			objects = append(objects, Object{
+				Key:          aws.StringValue(o.Key),
+				Size:         aws.Int64Value(o.Size),
+				LastModified: aws.TimeValue(o.LastModified),
+			})
+		}
+		return true
+	})
+	return objects, err
+}
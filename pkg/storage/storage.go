@@ -0,0 +1,12 @@
+package storage
+
+import (
+	"go.uber.org/fx"
+)
+
+// Module provides the object storage service backed by the configured S3 bucket
+var Module = fx.Options(
+	fx.Provide(
+		NewService,
+	),
+)
@@ -0,0 +1,117 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+// groupings of summaries, the expressions are never built from user input
+const (
+	byDay     = `to_char(?TableAlias.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')`
+	bySession = `?TableAlias.session_id`
+	byUser    = `COALESCE(u.username, ?TableAlias.user_id::text)`
+	byNothing = `''`
+	byVariant = `?TableAlias.variant`
+)
+
+type Repository interface {
+	insertRecord(context.Context, *Record) error
+	sumTokensSince(context.Context, int, time.Time) (int, error)
+	sumSessionTokens(context.Context, int, string) (int, error)
+	summarize(context.Context, int, string, time.Time, time.Time) ([]Summary, error)
+	summarizeExperiment(context.Context, int) ([]Summary, error)
+}
+
+// NewRepositoryIn is function param struct of func `NewDBRepository`
+type NewRepositoryIn struct {
+	fx.In
+
+	Log *logrus.Logger
+	DB  *pg.DB `name:"userdb"`
+}
+
+// PGRepo is postgres implementation
+type PGRepo struct {
+	log *logrus.Logger
+	db  *pg.DB
+}
+
+// NewDBRepository returns a new persistence layer object which can be used for
+// CRUD on db
+func NewDBRepository(i NewRepositoryIn) (Repo Repository, err error) {
+
+	Repo = &PGRepo{
+		log: i.Log,
+		db:  i.DB,
+	}
+
+	return
+}
+
+func (r *PGRepo) insertRecord(ctx context.Context, record *Record) error {
+	_, err := r.db.ModelContext(ctx, record).Insert()
+	return err
+}
+
+func (r *PGRepo) sumTokensSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	var total int
+	err := r.db.ModelContext(ctx, (*Record)(nil)).
+		ColumnExpr("COALESCE(SUM(?TableAlias.total_tokens), 0)").
+		Where("?TableAlias.user_id = ?", userID).
+		Where("?TableAlias.created_at >= ?", since).
+		Select(pg.Scan(&total))
+	return total, err
+}
+
+func (r *PGRepo) sumSessionTokens(ctx context.Context, userID int, sessionID string) (int, error) {
+	var total int
+	err := r.db.ModelContext(ctx, (*Record)(nil)).
+		ColumnExpr("COALESCE(SUM(?TableAlias.total_tokens), 0)").
+		Where("?TableAlias.user_id = ?", userID).
+		Where("?TableAlias.session_id = ?", sessionID).
+		Select(pg.Scan(&total))
+	return total, err
+}
+
+// summarize adds up the records between from and to grouped by the expression,
+// the records of every user when userID is 0
+func (r *PGRepo) summarize(ctx context.Context, userID int, groupBy string, from, to time.Time) ([]Summary, error) {
+	summaries := []Summary{}
+	q := r.summaryQuery(ctx, groupBy).
+		Where("?TableAlias.created_at >= ?", from).
+		Where("?TableAlias.created_at < ?", to)
+	if groupBy == byUser {
+		q = q.Join("LEFT JOIN users AS u ON u.id = ?TableAlias.user_id")
+	}
+	if userID != 0 {
+		q = q.Where("?TableAlias.user_id = ?", userID)
+	}
+	err := q.Select(&summaries)
+	return summaries, err
+}
+
+// summarizeExperiment adds up the records of the experiment by variant
+func (r *PGRepo) summarizeExperiment(ctx context.Context, experimentID int) ([]Summary, error) {
+	summaries := []Summary{}
+	err := r.summaryQuery(ctx, byVariant).
+		Where("?TableAlias.experiment_id = ?", experimentID).
+		Select(&summaries)
+	return summaries, err
+}
+
+func (r *PGRepo) summaryQuery(ctx context.Context, groupBy string) *orm.Query {
+	return r.db.ModelContext(ctx, (*Record)(nil)).
+		ColumnExpr(groupBy + " AS key").
+		ColumnExpr("COUNT(*) AS requests").
+		ColumnExpr("SUM(?TableAlias.prompt_tokens) AS prompt_tokens").
+		ColumnExpr("SUM(?TableAlias.completion_tokens) AS completion_tokens").
+		ColumnExpr("SUM(?TableAlias.total_tokens) AS total_tokens").
+		ColumnExpr("SUM(?TableAlias.cost) AS cost").
+		GroupExpr("key").
+		OrderExpr("key")
+}
@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemRepo keeps usage records in memory, it backs tests that run without postgres
+type MemRepo struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewMemRepository returns an empty in-memory persistence layer
+func NewMemRepository() Repository {
+	return &MemRepo{}
+}
+
+func (r *MemRepo) insertRecord(ctx context.Context, record *Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record.ID = len(r.records) + 1
+	r.records = append(r.records, *record)
+	return nil
+}
+
+func (r *MemRepo) sumTokensSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for _, record := range r.records {
+		if record.UserID == userID && !record.CreatedAt.Before(since) {
+			total += record.TotalTokens
+		}
+	}
+	return total, nil
+}
+
+func (r *MemRepo) sumSessionTokens(ctx context.Context, userID int, sessionID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for _, record := range r.records {
+		if record.UserID == userID && record.SessionID == sessionID {
+			total += record.TotalTokens
+		}
+	}
+	return total, nil
+}
+
+// summarize groups users by their ID, the usernames are in the users table
+func (r *MemRepo) summarize(ctx context.Context, userID int, groupBy string, from, to time.Time) ([]Summary, error) {
+	return r.group(groupBy, func(record Record) bool {
+		return (userID == 0 || record.UserID == userID) && !record.CreatedAt.Before(from) && record.CreatedAt.Before(to)
+	}), nil
+}
+
+func (r *MemRepo) summarizeExperiment(ctx context.Context, experimentID int) ([]Summary, error) {
+	return r.group(byVariant, func(record Record) bool { return record.ExperimentID == experimentID }), nil
+}
+
+func (r *MemRepo) group(groupBy string, match func(Record) bool) []Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byKey := map[string]*Summary{}
+	for _, record := range r.records {
+		if !match(record) {
+			continue
+		}
+		key := ""
+		switch groupBy {
+		case byDay:
+			key = record.CreatedAt.UTC().Format("2006-01-02")
+		case bySession:
+			key = record.SessionID
+		case byUser:
+			key = strconv.Itoa(record.UserID)
+		case byVariant:
+			key = record.Variant
+		}
+		summary, ok := byKey[key]
+		if !ok {
+			summary = &Summary{Key: key}
+			byKey[key] = summary
+		}
+		summary.Requests++
+		summary.PromptTokens += record.PromptTokens
+		summary.CompletionTokens += record.CompletionTokens
+		summary.TotalTokens += record.TotalTokens
+		summary.Cost += record.Cost
+	}
+	summaries := []Summary{}
+	for _, summary := range byKey {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Key < summaries[j].Key })
+	return summaries
+}
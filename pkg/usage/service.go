@@ -0,0 +1,144 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrDailyBudget   = errors.New("daily token budget exceeded")
+	ErrMonthlyBudget = errors.New("monthly token budget exceeded")
+)
+
+type Service struct {
+	conf   *viper.Viper
+	log    *logrus.Logger
+	Repo   Repository
+	prices map[string]Price
+}
+
+// NewService returns a usage accounting service object, it fails on invalid llm_prices.
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository) (*Service, error) {
+	prices, err := ParsePrices(conf.GetString(utils.LLMPrices))
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		conf:   conf,
+		log:    log,
+		Repo:   Repo,
+		prices: prices,
+	}, nil
+}
+
+// Record stores the tokens used by one completion request with its estimated cost,
+// models without a price in llm_prices cost nothing
+func (s *Service) Record(ctx context.Context, record *Record) error {
+	record.TotalTokens = record.PromptTokens + record.CompletionTokens
+	record.Cost = s.price(record.Model).cost(record.PromptTokens, record.CompletionTokens)
+	record.CreatedAt = time.Now()
+	return s.Repo.insertRecord(ctx, record)
+}
+
+// price returns the price of the model, or of the longest model name it starts with,
+// so that gpt-3.5-turbo-0125 reported by the API costs as much as gpt-3.5-turbo
+func (s *Service) price(model string) Price {
+	price, longest := Price{}, -1
+	for name, p := range s.prices {
+		if strings.HasPrefix(model, name) && len(name) > longest {
+			price, longest = p, len(name)
+		}
+	}
+	return price
+}
+
+// CheckBudget returns ErrDailyBudget or ErrMonthlyBudget once the user has spent a budget
+func (s *Service) CheckBudget(ctx context.Context, userID int) error {
+	budget, err := s.budget(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if budget.DailyTokens > 0 && budget.DailyUsed >= budget.DailyTokens {
+		return ErrDailyBudget
+	}
+	if budget.MonthlyTokens > 0 && budget.MonthlyUsed >= budget.MonthlyTokens {
+		return ErrMonthlyBudget
+	}
+	return nil
+}
+
+// SessionTokens returns the tokens the user has used in the chat session
+func (s *Service) SessionTokens(ctx context.Context, userID int, sessionID string) (int, error) {
+	return s.Repo.sumSessionTokens(ctx, userID, sessionID)
+}
+
+// Report returns the usage of the user between from and to by day and by session, with the budget left
+func (s *Service) Report(ctx context.Context, userID int, from, to time.Time) (*Report, error) {
+	report, err := s.report(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if report.Days, err = s.Repo.summarize(ctx, userID, byDay, from, to); err != nil {
+		return nil, err
+	}
+	if report.Sessions, err = s.Repo.summarize(ctx, userID, bySession, from, to); err != nil {
+		return nil, err
+	}
+	if report.Budget, err = s.budget(ctx, userID); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ExperimentReport returns the usage of each variant of the experiment
+func (s *Service) ExperimentReport(ctx context.Context, experimentID int) ([]Summary, error) {
+	return s.Repo.summarizeExperiment(ctx, experimentID)
+}
+
+// UsersReport returns the usage of every user between from and to
+func (s *Service) UsersReport(ctx context.Context, from, to time.Time) (*Report, error) {
+	report, err := s.report(ctx, 0, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if report.Users, err = s.Repo.summarize(ctx, 0, byUser, from, to); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *Service) report(ctx context.Context, userID int, from, to time.Time) (*Report, error) {
+	report := &Report{From: from, To: to}
+	total, err := s.Repo.summarize(ctx, userID, byNothing, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(total) == 1 {
+		report.Total = total[0]
+	}
+	return report, nil
+}
+
+// budget returns the budgets of the user and the tokens spent today and this month, days start at midnight UTC
+func (s *Service) budget(ctx context.Context, userID int) (*Budget, error) {
+	now := time.Now().UTC()
+	budget := &Budget{
+		DailyTokens:   s.conf.GetInt(utils.LLMDailyTokenBudget),
+		MonthlyTokens: s.conf.GetInt(utils.LLMMonthlyTokenBudget),
+	}
+	var err error
+	if budget.DailyUsed, err = s.Repo.sumTokensSince(ctx, userID, now.Truncate(24*time.Hour)); err != nil {
+		return nil, err
+	}
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if budget.MonthlyUsed, err = s.Repo.sumTokensSince(ctx, userID, monthStart); err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
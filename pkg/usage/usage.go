@@ -0,0 +1,95 @@
+package usage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// Module provides the LLM usage accounting service
+var Module = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewService,
+	),
+)
+
+type (
+	// Record is the token usage of one completion request
+	Record struct {
+		tableName struct{} `pg:"llm_usage,alias:usage,discard_unknown_columns"`
+		ID        int      `json:"id" pg:"id,pk"`
+		UserID    int      `json:"user_id" pg:"user_id"`
+		SessionID string   `json:"session_id" pg:"session_id"`
+		// ExperimentID and Variant are set when the session takes part in an experiment
+		ExperimentID     int       `json:"experiment_id,omitempty" pg:"experiment_id"`
+		Variant          string    `json:"variant,omitempty" pg:"variant"`
+		Model            string    `json:"model" pg:"model"`
+		PromptTokens     int       `json:"prompt_tokens" pg:"prompt_tokens,use_zero"`
+		CompletionTokens int       `json:"completion_tokens" pg:"completion_tokens,use_zero"`
+		TotalTokens      int       `json:"total_tokens" pg:"total_tokens,use_zero"`
+		Cost             float64   `json:"cost" pg:"cost,use_zero"`
+		CreatedAt        time.Time `json:"created_at" pg:"created_at"`
+	}
+	// Summary adds up the records of one day, session, user or variant
+	Summary struct {
+		Key              string  `json:"key"`
+		Requests         int     `json:"requests"`
+		PromptTokens     int     `json:"prompt_tokens"`
+		CompletionTokens int     `json:"completion_tokens"`
+		TotalTokens      int     `json:"total_tokens"`
+		Cost             float64 `json:"cost"`
+	}
+	// Budget is how many tokens a user may spend and has spent, a limit of 0 is unlimited
+	Budget struct {
+		DailyTokens   int `json:"daily_tokens"`
+		DailyUsed     int `json:"daily_used"`
+		MonthlyTokens int `json:"monthly_tokens"`
+		MonthlyUsed   int `json:"monthly_used"`
+	}
+	// Report is the usage between From and To, excluding To
+	Report struct {
+		From     time.Time `json:"from"`
+		To       time.Time `json:"to"`
+		Total    Summary   `json:"total"`
+		Days     []Summary `json:"days,omitempty"`
+		Sessions []Summary `json:"sessions,omitempty"`
+		Users    []Summary `json:"users,omitempty"`
+		Budget   *Budget   `json:"budget,omitempty"`
+	}
+	// Price is the estimated cost in USD of 1000 tokens
+	Price struct {
+		Prompt     float64
+		Completion float64
+	}
+)
+
+// ParsePrices reads space separated prices of the form `<model>=<prompt>:<completion>`,
+// in USD per 1000 tokens, e.g. `gpt-3.5-turbo=0.0005:0.0015`
+func ParsePrices(s string) (map[string]Price, error) {
+	prices := map[string]Price{}
+	for _, field := range strings.Fields(s) {
+		model, price, ok := strings.Cut(field, "=")
+		prompt, completion, ok2 := strings.Cut(price, ":")
+		if !ok || !ok2 || model == "" {
+			return nil, fmt.Errorf("llm price %q: want <model>=<prompt>:<completion>", field)
+		}
+		p := Price{}
+		var err, err2 error
+		p.Prompt, err = strconv.ParseFloat(prompt, 64)
+		p.Completion, err2 = strconv.ParseFloat(completion, 64)
+		if err != nil || err2 != nil || p.Prompt < 0 || p.Completion < 0 {
+			return nil, fmt.Errorf("llm price %q: prices must be positive numbers", field)
+		}
+		prices[model] = p
+	}
+	return prices, nil
+}
+
+// cost estimates the cost of the tokens
+func (p Price) cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion) / 1000
+}
@@ -0,0 +1,70 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/utils/types"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+type assignRoleArgs struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// RegisterAdminTools adds the chat tools that are only offered to users whose roles allow them
+func RegisterAdminTools(s *Service) {
+	s.RegisterRestrictedTool(openai.FunctionDefinition{
+		Name:        "QueueStats",
+		Description: "returns how many background jobs are ready, running, delayed and dead. use it when the user asks about the job queue",
+		Parameters:  jsonschema.Definition{Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{}},
+	}, types.QUEUEMANAGEMENT, s.queueStatsTool)
+	s.RegisterRestrictedTool(openai.FunctionDefinition{
+		Name:        "AssignRole",
+		Description: "gives a role to a user e.g., make alice an admin",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"username": {Type: jsonschema.String, Description: "the user that gets the role e.g., alice"},
+				"role":     {Type: jsonschema.String, Description: "the role name e.g., admin"},
+			},
+			Required: []string{"username", "role"},
+		},
+	}, types.USERMANAGEMENT, s.assignRoleTool)
+}
+
+func (s *Service) queueStatsTool(ctx context.Context, principal *User, raw json.RawMessage) (string, error) {
+	stats, err := s.queue.Stats(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d ready, %d running, %d delayed, %d dead", stats.Ready, stats.Inflight, stats.Delayed, stats.Dead), nil
+}
+
+func (s *Service) assignRoleTool(ctx context.Context, principal *User, raw json.RawMessage) (string, error) {
+	args := assignRoleArgs{}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	u, err := s.Repo.fetchUserByUsername(ctx, args.Username)
+	if err == pg.ErrNoRows {
+		return "user not found ask to check the username", nil
+	} else if err != nil {
+		return "", err
+	}
+	switch err := s.rbac.Assign(ctx, principal.ID, u.ID, args.Role); {
+	case err == rbac.ErrRoleNotFound:
+		return "role not found ask to check the role name", nil
+	case errors.Is(err, rbac.ErrEscalation):
+		return "the user can not assign this role, it has permissions they do not have", nil
+	case err != nil:
+		return "", err
+	}
+	return fmt.Sprintf("%s now has the role %s", u.Username, args.Role), nil
+}
@@ -0,0 +1,75 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	_pg "github.com/go-pg/pg/v10"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var (
+	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrWeakPassword       = errors.New("password must be at least 8 characters")
+)
+
+// dummyHash is compared against when the username does not exist,
+// so that a failed login takes the same time whether or not the user exists
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// Register creates a password protected user, it returns ErrUsernameTaken for any existing username
+func (s *Service) Register(ctx context.Context, user *User, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	user.Username = strings.TrimSpace(user.Username)
+	user.PasswordHash = hash
+	user.IsActive = true
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	ok, err := s.Repo.createUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+// Authenticate returns the user if the password matches
+func (s *Service) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	user, err := s.FetchUserByUsername(ctx, strings.TrimSpace(username))
+	if err == _pg.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" {
+		// accounts from before passwords existed must register a password first
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
@@ -0,0 +1,114 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/utils/types"
+)
+
+// permissions of a grant, write implies read
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+var (
+	ErrForbidden         = errors.New("you do not have access to this resource")
+	ErrInvalidPermission = errors.New("permission must be read or write")
+	ErrSelfGrant         = errors.New("you already own your photos")
+)
+
+// HasPermission reports whether one of the principal's roles grants the permission.
+// Failing to look the roles up denies the permission.
+func (s *PhotoService) HasPermission(ctx context.Context, principal *User, permission types.Permission) bool {
+	ok, err := s.rbac.HasPermission(ctx, principal.ID, permission)
+	if err != nil {
+		s.log.WithField("user_id", principal.ID).Error("rbac: " + err.Error())
+		return false
+	}
+	return ok
+}
+
+// Authorize returns ErrForbidden unless the principal owns the resources of ownerID,
+// was granted the permission on them, or has the photo management permission.
+// album narrows the check to one album, an empty album needs a grant on all photos.
+func (s *Service) Authorize(ctx context.Context, principal *User, ownerID int, album, permission string) error {
+	if principal.ID == ownerID || s.HasPermission(ctx, principal, types.PHOTOMANAGEMENT) {
+		return nil
+	}
+	ok, err := s.Repo.hasGrant(ctx, ownerID, principal.ID, album, permission)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Share grants another user access to the owner's photos, or to a single album when album is set.
+// Sharing again with the same user and album replaces the permission.
+func (s *Service) Share(ctx context.Context, owner *User, granteeUsername, album, permission string) (*Grant, error) {
+	if permission != PermissionRead && permission != PermissionWrite {
+		return nil, ErrInvalidPermission
+	}
+	grantee, err := s.FetchUserByUsername(ctx, strings.TrimSpace(granteeUsername))
+	if err != nil {
+		return nil, err
+	}
+	if grantee.ID == owner.ID {
+		return nil, ErrSelfGrant
+	}
+	grant := &Grant{
+		OwnerID:    owner.ID,
+		Owner:      owner,
+		GranteeID:  grantee.ID,
+		Grantee:    grantee,
+		Album:      strings.TrimSpace(album),
+		Permission: permission,
+		CreatedAt:  time.Now(),
+
+		OwnerUsername:   owner.Username,
+		GranteeUsername: grantee.Username,
+	}
+	if err := s.Repo.upsertGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// Grants returns the grants the user gave and received
+func (s *Service) Grants(ctx context.Context, userID int) (given, received []Grant, err error) {
+	if given, err = s.Repo.retrieveGivenGrants(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+	if received, err = s.Repo.retrieveReceivedGrants(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+	for _, grants := range [][]Grant{given, received} {
+		for i := range grants {
+			grants[i].OwnerUsername = grants[i].Owner.Username
+			grants[i].GranteeUsername = grants[i].Grantee.Username
+		}
+	}
+	return given, received, nil
+}
+
+// RevokeGrant deletes a grant given by the owner, it returns pg.ErrNoRows when there is none
+func (s *Service) RevokeGrant(ctx context.Context, ownerID, id int) error {
+	return s.Repo.deleteGrant(ctx, ownerID, id)
+}
+
+// fetchPhotoFor returns a photo the principal has the permission on
+func (s *Service) fetchPhotoFor(ctx context.Context, principal *User, photoID int, permission string) (*UserImages, error) {
+	photo, err := s.Repo.fetchPhoto(ctx, photoID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Authorize(ctx, principal, photo.UserID, photo.Album, permission); err != nil {
+		return nil, err
+	}
+	return photo, nil
+}
@@ -0,0 +1,362 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"uber_fx_init_folder_structure/pkg/cache"
+	"uber_fx_init_folder_structure/pkg/cache/persistence"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/experiment"
+	"uber_fx_init_folder_structure/pkg/llm"
+	"uber_fx_init_folder_structure/pkg/memory"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/usage"
+	"uber_fx_init_folder_structure/utils"
+	"uber_fx_init_folder_structure/utils/types"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const chatScript = `{
+  "fallback": "I did not get that.",
+  "rules": [
+    {
+      "match": "(?i)^hello (?P<name>\\w+)",
+      "reply": "Hi ${name}!"
+    },
+    {
+      "match": "(?i)^note (?P<text>.+) with priority (\\d+)$",
+      "tool_call": {"name": "SaveNote", "arguments": "{\"text\": \"${text}\", \"priority\": $2}"},
+      "reply": "Noted: $result"
+    },
+    {
+      "match": "(?i)^switch to (\\w+)$",
+      "tool_call": {"name": "SwitchAccount", "arguments": {"username": "$1"}},
+      "reply": "$result"
+    }
+  ]
+}`
+
+func TestProcessMessageScripted(t *testing.T) {
+	s, _ := newChatService(t)
+	var notes []json.RawMessage
+	s.RegisterTool(openai.FunctionDefinition{
+		Name: "SaveNote",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"text":     {Type: jsonschema.String},
+				"priority": {Type: jsonschema.Integer},
+			},
+		},
+	}, "", func(ctx context.Context, principal *User, args json.RawMessage) (string, error) {
+		notes = append(notes, args)
+		var note struct {
+			Text     string `json:"text"`
+			Priority int    `json:"priority"`
+		}
+		if err := json.Unmarshal(args, &note); err != nil {
+			return "", err
+		}
+		return note.Text + " at " + strconv.Itoa(note.Priority), nil
+	})
+	principal := &User{ID: 7, Username: "user07"}
+	session := Session{ID: "session-1", Locale: "en"}
+
+	reply, err := s.ProcessMessage(context.Background(), principal, session, "hello Asha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "Hi Asha!" {
+		t.Errorf("plain reply = %q, want %q", reply.Content, "Hi Asha!")
+	}
+	if len(notes) != 0 {
+		t.Errorf("a plain reply called the tool %d times", len(notes))
+	}
+
+	// the quotes must be escaped for the arguments to stay JSON, the number is expanded unquoted
+	reply, err = s.ProcessMessage(context.Background(), principal, session, `note say "cheese" with priority 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 {
+		t.Fatalf("the tool was called %d times, want once", len(notes))
+	}
+	if want := `{"text": "say \"cheese\"", "priority": 3}`; string(notes[0]) != want {
+		t.Errorf("tool arguments = %s, want %s", notes[0], want)
+	}
+	if want := `Noted: say "cheese" at 3`; reply.Content != want {
+		t.Errorf("tool reply = %q, want %q", reply.Content, want)
+	}
+	// both turns are stored, the second one with the tool call and its result
+	_, messages, err := s.conversation.History(context.Background(), principal.ID, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 6 {
+		t.Fatalf("stored %d messages, want 6", len(messages))
+	}
+	if reply.ID != messages[5].ID {
+		t.Errorf("reply ID = %d, want the stored answer %d", reply.ID, messages[5].ID)
+	}
+}
+
+func TestProcessMessageScopes(t *testing.T) {
+	s, _ := newChatService(t)
+	called := false
+	s.RegisterTool(openai.FunctionDefinition{Name: "SaveNote"}, "notes", func(context.Context, *User, json.RawMessage) (string, error) {
+		called = true
+		return "saved", nil
+	})
+	principal := &User{ID: 7, Username: "user07"}
+	// a key without the tool's scope is not offered the tool, the script then falls back
+	session := Session{ID: "session-1", Locale: "en", APIKey: true, Scopes: []string{"chat"}}
+
+	reply, err := s.ProcessMessage(context.Background(), principal, session, "note buy milk with priority 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if called || reply.Content != "I did not get that." {
+		t.Errorf("reply = %q, tool called = %v, want the fallback without calling the tool", reply.Content, called)
+	}
+}
+
+func TestProcessMessageSwitchAccount(t *testing.T) {
+	s, repo := newChatService(t)
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.users = []User{{ID: 7, Username: "user07"}, {ID: 8, Username: "user08", PasswordHash: hash}}
+	var actedFor []int
+	s.RegisterTool(openai.FunctionDefinition{Name: "SaveNote"}, "", func(ctx context.Context, principal *User, args json.RawMessage) (string, error) {
+		actedFor = append(actedFor, principal.ID)
+		return "saved", nil
+	})
+	signedIn := &repo.users[0]
+	session := Session{ID: "session-1", Locale: "en"}
+
+	replies := []string{}
+	for _, message := range []string{"switch to user08", "correct horse", "note buy milk with priority 1"} {
+		reply, err := s.ProcessMessage(context.Background(), signedIn, session, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply.Content)
+	}
+	if want := "this chat now acts on the account user08"; replies[1] != want {
+		t.Errorf("password reply = %q, want %q", replies[1], want)
+	}
+	// the message after the password acts for the account switched to
+	if len(actedFor) != 1 || actedFor[0] != 8 {
+		t.Errorf("the tool acted for users %v, want [8]", actedFor)
+	}
+	for _, userID := range []int{7, 8} {
+		_, messages, err := s.conversation.History(context.Background(), userID, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range messages {
+			if strings.Contains(m.Content, "correct horse") {
+				t.Errorf("the password was stored in the history of user %d", userID)
+			}
+		}
+	}
+}
+
+func TestToolsOfferedToAPIKeys(t *testing.T) {
+	s, _ := newChatService(t)
+	noop := func(context.Context, *User, json.RawMessage) (string, error) { return "", nil }
+	s.RegisterTool(openai.FunctionDefinition{Name: "SaveNote"}, "notes", noop)
+	s.RegisterRestrictedTool(openai.FunctionDefinition{Name: "AssignRole"}, types.USERMANAGEMENT, noop)
+	principal := &User{ID: 7, Username: "user07"}
+
+	tests := []struct {
+		scopes []string
+		want   string
+	}{
+		{[]string{"chat"}, ""},
+		{[]string{"photos:read"}, "FetchPhotos ListTrash"},
+		{[]string{"photos:read", "photos:write", "notes"}, "FetchPhotos ListTrash TrashPhoto RestorePhoto SaveNote"},
+	}
+	for _, tt := range tests {
+		names := []string{}
+		for _, tool := range s.CustomFunctionOpenAiParams(context.Background(), principal, Session{APIKey: true, Scopes: tt.scopes}) {
+			names = append(names, tool.Function.Name)
+		}
+		// keys never switch accounts or get the tools that need a permission
+		if got := strings.Join(names, " "); got != tt.want {
+			t.Errorf("scopes %v: offered %q, want %q", tt.scopes, got, tt.want)
+		}
+	}
+}
+
+// newChatService returns a service answering from chatScript, backed by in-memory repositories and cache
+func newChatService(t *testing.T) (*Service, *memRepo) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	conf := viper.New()
+	conf.Set(utils.DefaultLocale, "en")
+	conf.Set(utils.LLMPromptCacheTTL, time.Minute)
+	conf.Set(utils.MemoryPromptFacts, 10)
+
+	path := filepath.Join(t.TempDir(), "script.json")
+	if err := os.WriteFile(path, []byte(chatScript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chat, err := llm.NewScriptedModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := llm.NewProfiles(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheService := cache.NewService(conf, log, newMemoryStore())
+
+	usageService, err := usage.NewService(conf, log, usage.NewMemRepository())
+	if err != nil {
+		t.Fatal(err)
+	}
+	promptService, err := prompt.NewService(conf, log, prompt.NewMemRepository(), cacheService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversationService := conversation.NewService(log, conversation.NewMemRepository())
+	experimentService := experiment.NewService(conf, log, experiment.NewMemRepository(), cacheService, promptService, profiles, usageService, conversationService)
+	clock := func() time.Time { return time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC) }
+
+	repo := &memRepo{}
+	rbacService := rbac.NewService(log, rbac.NewMemRepository())
+	s := NewService(conf, log, repo, NewPhotoService(conf, log, repo, nil, rbacService), nil, nil, rbacService, usageService, chat, profiles, clock,
+		promptService, experimentService, conversationService, memory.NewService(conf, log, memory.NewMemRepository()), cacheService)
+	return s, repo
+}
+
+// memRepo keeps users and photos in memory. It implements what a chat message reads,
+// the embedded Repository is nil and any other call panics.
+type memRepo struct {
+	Repository
+	users  []User
+	photos []UserImages
+}
+
+func (r *memRepo) fetchUserByUsername(ctx context.Context, username string) (*User, error) {
+	for _, u := range r.users {
+		if u.Username == username {
+			return &u, nil
+		}
+	}
+	return &User{}, pg.ErrNoRows
+}
+
+func (r *memRepo) fetchUserByID(ctx context.Context, id int) (*User, error) {
+	for _, u := range r.users {
+		if u.ID == id {
+			return &u, nil
+		}
+	}
+	return &User{}, pg.ErrNoRows
+}
+
+func (r *memRepo) retrievePhotos(ctx context.Context, userID int) ([]UserImages, error) {
+	photos := []UserImages{}
+	for _, p := range r.photos {
+		if p.UserID == userID && p.IsActive {
+			photos = append(photos, p)
+		}
+	}
+	return photos, nil
+}
+
+func (r *memRepo) photoStats(ctx context.Context, userID int) (*PhotoStats, error) {
+	stats := &PhotoStats{Albums: []string{}}
+	for _, p := range r.photos {
+		switch {
+		case p.UserID != userID:
+		case p.IsActive:
+			stats.Count++
+		default:
+			stats.Trashed++
+		}
+	}
+	return stats, nil
+}
+
+// memoryStore is a cache store in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: map[string][]byte{}}
+}
+
+func (m *memoryStore) Get(key string, value interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.values[key]
+	if !ok {
+		return persistence.ErrCacheMiss
+	}
+	return utils.Deserialize(b, value)
+}
+
+func (m *memoryStore) Set(key string, value interface{}, expire time.Duration) error {
+	b, err := utils.Serialize(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = b
+	return nil
+}
+
+func (m *memoryStore) Add(key string, value interface{}, expire time.Duration) error {
+	return persistence.ErrNotSupport
+}
+
+func (m *memoryStore) Replace(key string, value interface{}, expire time.Duration) error {
+	return persistence.ErrNotSupport
+}
+
+func (m *memoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; !ok {
+		return persistence.ErrCacheMiss
+	}
+	delete(m.values, key)
+	return nil
+}
+
+func (m *memoryStore) Increment(key string, delta uint64) (uint64, error) {
+	return 0, persistence.ErrNotSupport
+}
+
+func (m *memoryStore) Decrement(key string, delta uint64) (uint64, error) {
+	return 0, persistence.ErrNotSupport
+}
+
+func (m *memoryStore) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values = map[string][]byte{}
+	return nil
+}
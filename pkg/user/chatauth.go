@@ -0,0 +1,94 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/pkg/cache/persistence"
+)
+
+// a switch to another account waits switchPasswordTTL for the password,
+// the chat then acts on that account for switchIdentityTTL
+const (
+	switchPasswordTTL = 5 * time.Minute
+	switchIdentityTTL = 30 * time.Minute
+)
+
+func switchPendingKey(signedInID int, sessionID string) string {
+	return fmt.Sprintf("chat:switch:%d:%s", signedInID, sessionID)
+}
+
+func switchIdentityKey(signedInID int, sessionID string) string {
+	return fmt.Sprintf("chat:identity:%d:%s", signedInID, sessionID)
+}
+
+// switchAccountTool starts switching the chat session to the account of username, the next message of the user
+// is taken as its password. The username of the signed in user switches back to their own account.
+func (s *Service) switchAccountTool(ctx context.Context, signedIn *User, session Session, username string) string {
+	username = strings.TrimSpace(username)
+	if username == "" || username == signedIn.Username {
+		if err := s.cache.Delete(switchIdentityKey(signedIn.ID, session.ID)); err != nil && err != persistence.ErrCacheMiss {
+			s.log.WithField("user_id", signedIn.ID).Error("failed to switch the chat back: " + err.Error())
+			return "something went wrong please try again"
+		}
+		return "the chat acts on the account " + signedIn.Username + " again"
+	}
+	if err := s.cache.Set(switchPendingKey(signedIn.ID, session.ID), username, switchPasswordTTL); err != nil {
+		s.log.WithField("user_id", signedIn.ID).Error("failed to start the account switch: " + err.Error())
+		return "something went wrong please try again"
+	}
+	return "ask the user to send the password of " + username + " as their next message, " +
+		"it is checked without being shown to you or stored, do not ask for it in any other way"
+}
+
+// switchAccount checks the message as the password of a pending account switch. It reports false when no switch
+// is pending, otherwise the message is neither sent to the model, stored nor logged.
+func (s *Service) switchAccount(ctx context.Context, signedIn *User, session Session, message string) (Reply, bool, error) {
+	var username string
+	key := switchPendingKey(signedIn.ID, session.ID)
+	if err := s.cache.Get(key, &username); err != nil {
+		if err != persistence.ErrCacheMiss {
+			s.log.WithField("user_id", signedIn.ID).Error("failed to read the account switch: " + err.Error())
+		}
+		return Reply{}, false, nil
+	}
+	// one password per switch, a wrong one has to ask the bot again
+	if err := s.cache.Delete(key); err != nil && err != persistence.ErrCacheMiss {
+		return Reply{}, true, err
+	}
+	user, err := s.Authenticate(ctx, username, strings.TrimSpace(message))
+	if err == ErrInvalidCredentials {
+		return Reply{Content: wrongPasswordResp}, true, nil
+	}
+	if err != nil {
+		return Reply{}, true, err
+	}
+	if err := s.cache.Set(switchIdentityKey(signedIn.ID, session.ID), strconv.Itoa(user.ID), switchIdentityTTL); err != nil {
+		return Reply{}, true, err
+	}
+	return Reply{Content: "this chat now acts on the account " + user.Username}, true, nil
+}
+
+// chatIdentity returns the user the chat session acts on, the signed in user unless they switched accounts
+func (s *Service) chatIdentity(ctx context.Context, signedIn *User, session Session) *User {
+	var userID string
+	if err := s.cache.Get(switchIdentityKey(signedIn.ID, session.ID), &userID); err != nil {
+		if err != persistence.ErrCacheMiss {
+			s.log.WithField("user_id", signedIn.ID).Error("failed to read the chat identity: " + err.Error())
+		}
+		return signedIn
+	}
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		s.log.WithField("user_id", signedIn.ID).Error("invalid chat identity: " + err.Error())
+		return signedIn
+	}
+	user, err := s.FetchUserByID(ctx, id)
+	if err != nil {
+		s.log.WithField("user_id", signedIn.ID).Error("failed to load the switched account: " + err.Error())
+		return signedIn
+	}
+	return user
+}
@@ -2,17 +2,42 @@ package user
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/fx"
 )
 
 type Repository interface {
 	upsertUserRegistration(context.Context, *User) error
+	createUser(context.Context, *User) (bool, error)
 	fetchUserByUsername(context.Context, string) (*User, error)
-	userUploadPhoto(context.Context, UserImages) error
+	fetchUserByID(context.Context, int) (*User, error)
+	fetchUserBySSO(context.Context, string, string) (*User, error)
+	linkSSO(context.Context, int, string, string) (bool, error)
+	insertUser(context.Context, *User) (bool, error)
+	userUploadPhoto(context.Context, *UserImages) error
+	fetchPhoto(context.Context, int) (*UserImages, error)
+	updateThumbnail(context.Context, int, string) error
+	updateMetadata(context.Context, int, time.Time, map[string]string) error
 	retrievePhotos(context.Context, int) ([]UserImages, error)
+	photoStats(context.Context, int) (*PhotoStats, error)
+	retrievePhotoPage(context.Context, int, PhotoFilter, *photoCursor) ([]UserImages, error)
+	trashPhoto(context.Context, int, int) error
+	retrieveTrash(context.Context, int) ([]UserImages, error)
+	restorePhoto(context.Context, int, int) error
+	retrieveExpiredTrash(context.Context, time.Time, int, int) ([]UserImages, error)
+	deletePhoto(context.Context, int) error
+	retrieveAllPhotos(context.Context, int, int) ([]UserImages, error)
+	deactivatePhotos(context.Context, []int) error
+	hasGrant(context.Context, int, int, string, string) (bool, error)
+	upsertGrant(context.Context, *Grant) error
+	retrieveGivenGrants(context.Context, int) ([]Grant, error)
+	retrieveReceivedGrants(context.Context, int) ([]Grant, error)
+	deleteGrant(context.Context, int, int) error
 }
 
 // NewRepositoryIn is function param struct of func `NewRepository`
@@ -60,18 +85,319 @@ func (r *PGRepo) upsertUserRegistration(dCtx context.Context, req *User) error {
 	return err
 }
 
+// createUser inserts a user with a password unless the username exists, including usernames
+// created before passwords existed. It reports whether the user was stored.
+func (r *PGRepo) createUser(dCtx context.Context, req *User) (bool, error) {
+	res, err := r.db.ModelContext(dCtx, req).
+		OnConflict("(username) DO NOTHING").
+		Returning("id, created_at").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
 func (r *PGRepo) fetchUserByUsername(dCtx context.Context, username string) (res *User, err error) {
 	res = &User{}
 	err = r.db.ModelContext(dCtx, res).Where("username = ?", username).Select()
 	return res, err
 }
 
-func (r *PGRepo) userUploadPhoto(ctx context.Context, userImages UserImages) error {
-	_, err := r.db.ModelContext(ctx, &userImages).Insert()
+func (r *PGRepo) fetchUserByID(dCtx context.Context, id int) (res *User, err error) {
+	res = &User{}
+	err = r.db.ModelContext(dCtx, res).Where("id = ?", id).Select()
+	return res, err
+}
+
+func (r *PGRepo) fetchUserBySSO(dCtx context.Context, issuer, subject string) (res *User, err error) {
+	res = &User{}
+	err = r.db.ModelContext(dCtx, res).
+		Where("oidc_issuer = ?", issuer).
+		Where("oidc_subject = ?", subject).
+		Select()
+	return res, err
+}
+
+// linkSSO links the identity to the user unless the user is linked already, it reports whether the user was linked
+func (r *PGRepo) linkSSO(dCtx context.Context, userID int, issuer, subject string) (bool, error) {
+	res, err := r.db.ModelContext(dCtx, (*User)(nil)).
+		Set("oidc_issuer = ?", issuer).
+		Set("oidc_subject = ?", subject).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", userID).
+		Where("oidc_subject IS NULL").
+		Update()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// insertUser inserts a user unless the username exists, it reports whether the user was stored
+func (r *PGRepo) insertUser(dCtx context.Context, req *User) (bool, error) {
+	res, err := r.db.ModelContext(dCtx, req).
+		OnConflict("(username) DO NOTHING").
+		Returning("id").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+func (r *PGRepo) userUploadPhoto(ctx context.Context, userImages *UserImages) error {
+	_, err := r.db.ModelContext(ctx, userImages).Insert()
+	return err
+}
+
+func (r *PGRepo) fetchPhoto(ctx context.Context, photoID int) (*UserImages, error) {
+	res := &UserImages{}
+	err := r.db.ModelContext(ctx, res).Where("id = ?", photoID).Select()
+	return res, err
+}
+
+func (r *PGRepo) updateThumbnail(ctx context.Context, photoID int, url string) error {
+	_, err := r.db.ModelContext(ctx, (*UserImages)(nil)).
+		Set("thumbnail_url = ?", url).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", photoID).
+		Update()
+	return err
+}
+
+func (r *PGRepo) updateMetadata(ctx context.Context, photoID int, takenAt time.Time, exif map[string]string) error {
+	q := r.db.ModelContext(ctx, (*UserImages)(nil)).
+		Set("exif = ?", exif).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", photoID)
+	if !takenAt.IsZero() {
+		q.Set("taken_at = ?", takenAt)
+	}
+	_, err := q.Update()
 	return err
 }
 func (r *PGRepo) retrievePhotos(ctx context.Context, userID int) ([]UserImages, error) {
 	userImages := []UserImages{}
-	err := r.db.ModelContext(ctx, &userImages).Where("user_id = ?", userID).Select()
+	err := r.db.ModelContext(ctx, &userImages).
+		Where("user_id = ?", userID).
+		Where("is_active = ?", true).
+		Select()
+	return userImages, err
+}
+
+// photoStats counts the user's photos and lists the albums of the ones not in the trash
+func (r *PGRepo) photoStats(ctx context.Context, userID int) (*PhotoStats, error) {
+	stats := &PhotoStats{Albums: []string{}}
+	err := r.db.ModelContext(ctx, (*UserImages)(nil)).
+		ColumnExpr("COUNT(*) FILTER (WHERE is_active)").
+		ColumnExpr("COUNT(*) FILTER (WHERE NOT is_active AND deleted_at IS NOT NULL)").
+		ColumnExpr("COALESCE(ARRAY_AGG(DISTINCT album) FILTER (WHERE is_active AND album <> ''), '{}')").
+		Where("user_id = ?", userID).
+		Select(pg.Scan(&stats.Count, &stats.Trashed, pg.Array(&stats.Albums)))
+	return stats, err
+}
+
+// retrievePhotoPage returns up to `filter.Limit+1` active photos after the cursor
+// so that the caller can tell whether there is a next page
+func (r *PGRepo) retrievePhotoPage(ctx context.Context, userID int, filter PhotoFilter, after *photoCursor) ([]UserImages, error) {
+	userImages := []UserImages{}
+	column, desc, err := parseSort(filter.Sort)
+	if err != nil {
+		return nil, err
+	}
+	cmp, dir := ">", "ASC"
+	if desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	q := r.db.ModelContext(ctx, &userImages).
+		Where("user_id = ?", userID).
+		Where("is_active = ?", true)
+	if !filter.From.IsZero() {
+		q.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q.Where("created_at < ?", filter.To)
+	}
+	if filter.Tag != "" {
+		q.Where("? = ANY(tags)", filter.Tag)
+	}
+	if filter.Album != "" {
+		q.Where("album = ?", filter.Album)
+	}
+	if strings.HasSuffix(filter.ContentType, "/*") {
+		q.Where("content_type LIKE ?", strings.TrimSuffix(filter.ContentType, "*")+"%")
+	} else if filter.ContentType != "" {
+		q.Where("content_type = ?", filter.ContentType)
+	}
+
+	switch column {
+	case "id":
+		if after != nil {
+			q.Where("id "+cmp+" ?", after.ID)
+		}
+		q.OrderExpr("id " + dir)
+	default:
+		if after != nil {
+			q.Where("(created_at, id) "+cmp+" (?, ?)", after.CreatedAt, after.ID)
+		}
+		q.OrderExpr("created_at " + dir).OrderExpr("id " + dir)
+	}
+
+	err = q.Limit(filter.Limit + 1).Select()
+	return userImages, err
+}
+
+func (r *PGRepo) trashPhoto(ctx context.Context, userID, photoID int) error {
+	now := time.Now()
+	res, err := r.db.ModelContext(ctx, (*UserImages)(nil)).
+		Set("is_active = ?", false).
+		Set("deleted_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("id = ?", photoID).
+		Where("user_id = ?", userID).
+		Where("is_active = ?", true).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func (r *PGRepo) retrieveTrash(ctx context.Context, userID int) ([]UserImages, error) {
+	userImages := []UserImages{}
+	err := r.db.ModelContext(ctx, &userImages).
+		Where("user_id = ?", userID).
+		Where("is_active = ?", false).
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").
+		Select()
+	return userImages, err
+}
+
+func (r *PGRepo) restorePhoto(ctx context.Context, userID, photoID int) error {
+	res, err := r.db.ModelContext(ctx, (*UserImages)(nil)).
+		Set("is_active = ?", true).
+		Set("deleted_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", photoID).
+		Where("user_id = ?", userID).
+		Where("is_active = ?", false).
+		Where("deleted_at IS NOT NULL").
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+// retrieveExpiredTrash returns up to limit photos trashed before the time with an ID above afterID,
+// so that photos that could not be purged are skipped rather than fetched again
+func (r *PGRepo) retrieveExpiredTrash(ctx context.Context, before time.Time, afterID, limit int) ([]UserImages, error) {
+	userImages := []UserImages{}
+	err := r.db.ModelContext(ctx, &userImages).
+		Where("is_active = ?", false).
+		Where("deleted_at < ?", before).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Select()
+	return userImages, err
+}
+
+func (r *PGRepo) deletePhoto(ctx context.Context, photoID int) error {
+	_, err := r.db.ModelContext(ctx, (*UserImages)(nil)).Where("id = ?", photoID).Delete()
+	return err
+}
+
+// retrieveAllPhotos returns photos of every user, active or not, ordered by id starting after afterID
+func (r *PGRepo) retrieveAllPhotos(ctx context.Context, afterID, limit int) ([]UserImages, error) {
+	userImages := []UserImages{}
+	err := r.db.ModelContext(ctx, &userImages).
+		Column("id", "user_id", "url", "thumbnail_url", "is_active", "deleted_at", "created_at").
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Select()
 	return userImages, err
 }
+
+// deactivatePhotos hides photos without moving them to the trash
+func (r *PGRepo) deactivatePhotos(ctx context.Context, photoIDs []int) error {
+	_, err := r.db.ModelContext(ctx, (*UserImages)(nil)).
+		Set("is_active = ?", false).
+		Set("updated_at = ?", time.Now()).
+		WhereIn("id IN (?)", photoIDs).
+		Update()
+	return err
+}
+
+// hasGrant reports whether the owner granted the permission to the grantee on all photos or on the album
+func (r *PGRepo) hasGrant(ctx context.Context, ownerID, granteeID int, album, permission string) (bool, error) {
+	q := r.db.ModelContext(ctx, (*Grant)(nil)).
+		Where("owner_id = ?", ownerID).
+		Where("grantee_id = ?", granteeID).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			q = q.Where("album IS NULL")
+			if album != "" {
+				q = q.WhereOr("album = ?", album)
+			}
+			return q, nil
+		})
+	if permission == PermissionWrite {
+		q = q.Where("permission = ?", PermissionWrite)
+	}
+	return q.Exists()
+}
+
+func (r *PGRepo) upsertGrant(ctx context.Context, grant *Grant) error {
+	_, err := r.db.ModelContext(ctx, grant).
+		OnConflict("(owner_id, grantee_id, (COALESCE(album, ''))) DO UPDATE").
+		Set("permission = EXCLUDED.permission").
+		Returning("id, created_at").
+		Insert()
+	return err
+}
+
+func (r *PGRepo) retrieveGivenGrants(ctx context.Context, ownerID int) ([]Grant, error) {
+	grants := []Grant{}
+	err := r.db.ModelContext(ctx, &grants).
+		Relation("Owner").
+		Relation("Grantee").
+		Where("photo_grant.owner_id = ?", ownerID).
+		Order("photo_grant.id").
+		Select()
+	return grants, err
+}
+
+func (r *PGRepo) retrieveReceivedGrants(ctx context.Context, granteeID int) ([]Grant, error) {
+	grants := []Grant{}
+	err := r.db.ModelContext(ctx, &grants).
+		Relation("Owner").
+		Relation("Grantee").
+		Where("photo_grant.grantee_id = ?", granteeID).
+		Order("photo_grant.id").
+		Select()
+	return grants, err
+}
+
+func (r *PGRepo) deleteGrant(ctx context.Context, ownerID, id int) error {
+	res, err := r.db.ModelContext(ctx, (*Grant)(nil)).
+		Where("id = ?", id).
+		Where("owner_id = ?", ownerID).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/experiment"
+	"uber_fx_init_folder_structure/pkg/llm"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/utils"
+	"uber_fx_init_folder_structure/utils/bot"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// transcriptToolResultChars is the longest tool result written into the transcript the model
+// summarizes, the latest result of each tool is kept verbatim with the summary anyway
+const transcriptToolResultChars = 500
+
+// history returns the earlier turns of the session to send before the message. When the dialogue
+// would exceed llm_context_token_budget, every message before the latest llm_recent_turns is summarized
+// by the model into the session's rolling summary, and the latest result of each tool they called is
+// kept verbatim. Fewer turns are kept while they do not fit next to the summary. The message is answered
+// without the older turns when they can not be summarized.
+func (s *Service) history(ctx context.Context, principal *User, session Session, assignment *experiment.Assignment, persona []string, message string, tools []openai.Tool) []openai.ChatCompletionMessage {
+	summary, messages, err := s.conversation.History(ctx, principal.ID, session.ID)
+	if err != nil {
+		s.log.WithField("session_id", session.ID).Error("failed to load the chat history: " + err.Error())
+		return nil
+	}
+	budget := s.conf.GetInt(utils.LLMContextTokenBudget)
+	fits := func(history []openai.ChatCompletionMessage) bool {
+		return budget <= 0 || llm.CountTokens(bot.Dialogue(principal.Username, persona, history, message), tools) <= budget
+	}
+	turns := splitTurns(messages)
+	history := historyMessages(summary, turns)
+	if fits(history) || len(messages) == 0 {
+		return history
+	}
+	recent := s.conf.GetInt(utils.LLMRecentTurns)
+	if recent < 0 {
+		recent = 0
+	}
+	if recent > len(turns) {
+		recent = len(turns)
+	}
+	for {
+		// the turns kept verbatim have to fit next to the summary
+		for recent > 0 && !fits(historyMessages(summary, turns[len(turns)-recent:])) {
+			recent--
+		}
+		kept := turns[len(turns)-recent:]
+		beforeID := messages[len(messages)-1].ID + 1
+		if recent > 0 {
+			beforeID = kept[0][0].ID
+		}
+		// a failed compaction still keeps the pages summarized before it
+		summary, err = s.compact(ctx, principal, session, assignment, summary, beforeID)
+		if err != nil {
+			s.log.WithField("session_id", session.ID).Error("failed to summarize the chat: " + err.Error())
+			return historyMessages(summary, kept)
+		}
+		history = historyMessages(summary, kept)
+		if recent == 0 || fits(history) {
+			return history
+		}
+		// the new summary left no room for every kept turn
+		recent--
+	}
+}
+
+// compact summarizes the messages of the session after the summary and before beforeID into it, a page
+// of messages at a time so that those older than the loaded history are summarized too. It returns the
+// summary unchanged when there is nothing to summarize.
+func (s *Service) compact(ctx context.Context, principal *User, session Session, assignment *experiment.Assignment, summary *conversation.Summary, beforeID int) (*conversation.Summary, error) {
+	afterID := 0
+	if summary != nil {
+		afterID = summary.UpToID
+	}
+	err := s.conversation.Messages(ctx, principal.ID, session.ID, afterID, beforeID, func(messages []conversation.Message) error {
+		compacted, err := s.summarize(ctx, principal, session, assignment, summary, messages)
+		if err != nil {
+			return err
+		}
+		summary = compacted
+		return nil
+	})
+	return summary, err
+}
+
+// summarize has the model summarize the older messages together with the previous summary and stores
+// the new summary of the session
+func (s *Service) summarize(ctx context.Context, principal *User, session Session, assignment *experiment.Assignment, previous *conversation.Summary, older []conversation.Message) (*conversation.Summary, error) {
+	instructions, err := s.prompt.Render(ctx, prompt.Summary, 0, s.promptContext(ctx, principal, session, ""))
+	if err != nil {
+		return nil, err
+	}
+	summary := &conversation.Summary{
+		UserID:      principal.ID,
+		SessionID:   session.ID,
+		ToolResults: []conversation.ToolResult{},
+	}
+	transcript := &strings.Builder{}
+	if previous != nil {
+		summary.Turns = previous.Turns
+		summary.ToolResults = append(summary.ToolResults, previous.ToolResults...)
+		if previous.Content != "" {
+			fmt.Fprintf(transcript, "Summary of the conversation so far:\n%s\n\nThe conversation continued:\n", previous.Content)
+		}
+	}
+	for _, m := range older {
+		switch m.Role {
+		case conversation.RoleUser:
+			fmt.Fprintf(transcript, "User: %s\n", m.Content)
+			summary.Turns++
+		case conversation.RoleAssistant:
+			fmt.Fprintf(transcript, "Assistant: %s\n", m.Content)
+		case conversation.RoleToolCall:
+			for _, call := range m.ToolCalls {
+				fmt.Fprintf(transcript, "Assistant called the tool %s with %s\n", call.Function.Name, call.Function.Arguments)
+			}
+		case conversation.RoleTool:
+			fmt.Fprintf(transcript, "Result of %s: %s\n", m.Name, truncate(m.Content, transcriptToolResultChars))
+			summary.ToolResults = keepToolResult(summary.ToolResults, conversation.ToolResult{Name: m.Name, Content: m.Content})
+		}
+		summary.UpToID = m.ID
+	}
+
+	dialogue := []openai.ChatCompletionMessage{}
+	for _, content := range instructions.Messages {
+		dialogue = append(dialogue, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: content})
+	}
+	dialogue = append(dialogue, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: transcript.String()})
+	req := openai.ChatCompletionRequest{Messages: dialogue}
+	s.profile(ctx, principal, llm.StageSummary, assignment).Apply(&req)
+	resp, err := s.chat.CreateChatCompletion(ctx, req)
+	if err != nil || len(resp.Choices) != 1 {
+		return nil, fmt.Errorf("summary completion error: %v len(choices): %v", err, len(resp.Choices))
+	}
+	s.recordUsage(ctx, principal, session.ID, assignment, resp)
+	if summary.Content = strings.TrimSpace(resp.Choices[0].Message.Content); summary.Content == "" {
+		return nil, errors.New("the model returned an empty summary")
+	}
+	if err := s.conversation.SaveSummary(ctx, summary); err != nil {
+		// the summary still answers this message, the next one summarizes again
+		s.log.WithField("session_id", session.ID).Error("failed to store the chat summary: " + err.Error())
+	}
+	return summary, nil
+}
+
+// splitTurns splits the messages into turns, each starting with a message of the user. Messages
+// before the first one are the end of a turn cut off by the history limit and are dropped, compacting
+// summarizes them.
+func splitTurns(messages []conversation.Message) [][]conversation.Message {
+	turns := [][]conversation.Message{}
+	for _, m := range messages {
+		if m.Role == conversation.RoleUser {
+			turns = append(turns, []conversation.Message{m})
+			continue
+		}
+		if len(turns) > 0 {
+			turns[len(turns)-1] = append(turns[len(turns)-1], m)
+		}
+	}
+	return turns
+}
+
+// historyMessages returns the summary, the tool results kept with it and the turns as the messages
+// sent to the model
+func historyMessages(summary *conversation.Summary, turns [][]conversation.Message) []openai.ChatCompletionMessage {
+	history := []openai.ChatCompletionMessage{}
+	if summary != nil {
+		if summary.Content != "" {
+			history = append(history, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "summary of the earlier conversation: " + summary.Content,
+			})
+		}
+		for _, result := range summary.ToolResults {
+			history = append(history, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "latest result of the tool " + result.Name + " in the earlier conversation: " + result.Content,
+			})
+		}
+	}
+	for _, turn := range turns {
+		for _, m := range turn {
+			history = append(history, chatMessage(m))
+		}
+	}
+	return history
+}
+
+// chatMessage returns the stored message as it is sent to the model
+func chatMessage(m conversation.Message) openai.ChatCompletionMessage {
+	switch m.Role {
+	case conversation.RoleToolCall:
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: m.Content, ToolCalls: m.ToolCalls}
+	case conversation.RoleTool:
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, Content: m.Content, Name: m.Name, ToolCallID: m.ToolCallID}
+	case conversation.RoleAssistant:
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: m.Content}
+	}
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: m.Content}
+}
+
+// keepToolResult returns the results with the result replacing an earlier result of the same tool,
+// the latest result last
+func keepToolResult(results []conversation.ToolResult, result conversation.ToolResult) []conversation.ToolResult {
+	kept := []conversation.ToolResult{}
+	for _, r := range results {
+		if r.Name != result.Name {
+			kept = append(kept, r)
+		}
+	}
+	return append(kept, result)
+}
+
+// truncate returns the first n characters of the text, marked as cut when it is longer
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "…"
+}
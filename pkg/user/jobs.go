@@ -0,0 +1,104 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"uber_fx_init_folder_structure/pkg/media"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/utils"
+
+	_pg "github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// background job types of the user package
+const (
+	JobThumbnail  = "photo.thumbnail"
+	JobExif       = "photo.exif"
+	JobTrashPurge = "trash.purge"
+
+	purgeBatchSize = 100
+)
+
+type photoJob struct {
+	PhotoID int `json:"photo_id"`
+}
+
+// RegisterJobs registers the photo post-processing, trash purge and reconcile jobs on the queue
+func RegisterJobs(q *queue.Service, s *PhotoService) {
+	q.Register(JobThumbnail, s.thumbnailJob)
+	q.Register(JobExif, s.exifJob)
+	q.Register(JobTrashPurge, s.trashPurgeJob)
+	q.Register(JobReconcile, s.reconcileJob)
+	q.Schedule(JobTrashPurge, s.conf.GetDuration(utils.TrashPurgeInterval))
+	q.Schedule(JobReconcile, s.conf.GetDuration(utils.ReconcileInterval))
+}
+
+// thumbnailJob stores a downscaled JPEG of the photo next to the original
+func (s *PhotoService) thumbnailJob(ctx context.Context, job queue.Job) error {
+	photo, err := s.photoForJob(ctx, job)
+	if photo == nil || err != nil {
+		return err
+	}
+	key := s.storage.KeyFromURL(photo.Url)
+	body, err := s.storage.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	thumb, err := media.Thumbnail(body, s.conf.GetInt(utils.ThumbnailSize))
+	if err != nil {
+		// not an image we can decode, retrying will not help
+		s.log.WithField("photo_id", photo.ID).Warn("thumbnail skipped: " + err.Error())
+		return nil
+	}
+	thumbKey := "thumbnails/" + key + ".jpg"
+	if err := s.storage.Upload(ctx, thumbKey, bytes.NewReader(thumb), "image/jpeg"); err != nil {
+		return err
+	}
+	return s.Repo.updateThumbnail(ctx, photo.ID, s.storage.URL(thumbKey))
+}
+
+// exifJob stores the capture date and EXIF tags of the photo
+func (s *PhotoService) exifJob(ctx context.Context, job queue.Job) error {
+	photo, err := s.photoForJob(ctx, job)
+	if photo == nil || err != nil {
+		return err
+	}
+	body, err := s.storage.Open(ctx, s.storage.KeyFromURL(photo.Url))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	md, err := media.ReadMetadata(body)
+	if err != nil {
+		// most PNGs and screenshots carry no EXIF data
+		s.log.WithField("photo_id", photo.ID).Info("no exif data: " + err.Error())
+		return nil
+	}
+	return s.Repo.updateMetadata(ctx, photo.ID, md.TakenAt, md.Tags)
+}
+
+func (s *PhotoService) trashPurgeJob(ctx context.Context, job queue.Job) error {
+	purged, failed, err := s.PurgeTrash(ctx)
+	if purged > 0 || failed > 0 {
+		s.log.WithFields(logrus.Fields{"purged": purged, "failed": failed}).Info("trash purged")
+	}
+	return err
+}
+
+// photoForJob loads the photo a job refers to, it returns nil if the photo is already gone
+func (s *PhotoService) photoForJob(ctx context.Context, job queue.Job) (*UserImages, error) {
+	payload := photoJob{}
+	if err := job.Decode(&payload); err != nil {
+		return nil, err
+	}
+	photo, err := s.Repo.fetchPhoto(ctx, payload.PhotoID)
+	if err == _pg.ErrNoRows {
+		s.log.WithFields(logrus.Fields{"photo_id": payload.PhotoID, "type": job.Type}).Warn("photo deleted before job ran")
+		return nil, nil
+	}
+	return photo, err
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/memory"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+type saveMemoryArgs struct {
+	Fact string `json:"fact"`
+}
+
+type forgetMemoryArgs struct {
+	ID int `json:"id"`
+}
+
+// RegisterMemoryTools adds the chat tools that save, list and forget the facts the bot remembers about the user
+func RegisterMemoryTools(s *Service) {
+	s.RegisterTool(openai.FunctionDefinition{
+		Name:        "SaveMemory",
+		Description: "remembers a lasting fact or preference of the user for later chats e.g., my trips are always in the goa album, reply in hindi. only save what the user asked to remember or clearly wants kept",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"fact": {Type: jsonschema.String, Description: "the fact in a short sentence e.g., trips go in the goa album"},
+			},
+			Required: []string{"fact"},
+		},
+	}, apikey.ScopeChat, s.saveMemoryTool)
+	s.RegisterTool(openai.FunctionDefinition{
+		Name:        "ListMemories",
+		Description: "lists every fact remembered about the user with its id. use it when the user asks what you remember or before forgetting a fact",
+		Parameters:  jsonschema.Definition{Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{}},
+	}, apikey.ScopeChat, s.listMemoriesTool)
+	s.RegisterTool(openai.FunctionDefinition{
+		Name:        "ForgetMemory",
+		Description: "forgets a fact remembered about the user, call ListMemories first to find its id",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"id": {Type: jsonschema.Integer, Description: "the id of the fact from ListMemories"},
+			},
+			Required: []string{"id"},
+		},
+	}, apikey.ScopeChat, s.forgetMemoryTool)
+}
+
+func (s *Service) saveMemoryTool(ctx context.Context, principal *User, raw json.RawMessage) (string, error) {
+	args := saveMemoryArgs{}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	m, err := s.memory.Save(ctx, principal.ID, args.Fact)
+	switch {
+	case err == memory.ErrMemoryExists:
+		return "this is remembered already", nil
+	case err == memory.ErrMemoryFull:
+		return "too many facts are remembered, ask the user which one to forget", nil
+	case errors.Is(err, memory.ErrInvalidMemory):
+		return err.Error(), nil
+	case err != nil:
+		return "", err
+	}
+	return fmt.Sprintf("remembered %d: %s", m.ID, m.Content), nil
+}
+
+func (s *Service) listMemoriesTool(ctx context.Context, principal *User, raw json.RawMessage) (string, error) {
+	memories, err := s.memory.Memories(ctx, principal.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(memories) == 0 {
+		return "nothing is remembered about the user", nil
+	}
+	lines := []string{}
+	for _, m := range memories {
+		lines = append(lines, fmt.Sprintf("%d: %s", m.ID, m.Content))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (s *Service) forgetMemoryTool(ctx context.Context, principal *User, raw json.RawMessage) (string, error) {
+	args := forgetMemoryArgs{}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	switch err := s.memory.Forget(ctx, principal.ID, args.ID); {
+	case err == memory.ErrMemoryNotFound:
+		return "memory not found call ListMemories to check the id", nil
+	case err != nil:
+		return "", err
+	}
+	return fmt.Sprintf("forgot %d", args.ID), nil
+}
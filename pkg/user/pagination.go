@@ -0,0 +1,93 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+	defaultPhotoSort = "-created_at"
+)
+
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrCursorMismatch = errors.New("the cursor belongs to a listing with other filters or another sort")
+	ErrInvalidSort    = errors.New("invalid sort, use one of created_at, -created_at, id, -id")
+)
+
+// sortColumns are the user_images columns a photo listing can be sorted by
+var sortColumns = map[string]bool{
+	"created_at": true,
+	"id":         true,
+}
+
+// photoCursor is the position of the last photo of a page, with a hash of the sort and filters of the
+// listing it belongs to since the position means nothing in another listing
+type photoCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+	Listing   string    `json:"l"`
+}
+
+// parseSort splits a sort param like `-created_at` into its column and direction
+func parseSort(sort string) (column string, desc bool, err error) {
+	sort = listingSort(sort)
+	column = strings.TrimPrefix(sort, "-")
+	if !sortColumns[column] {
+		return "", false, ErrInvalidSort
+	}
+	return column, strings.HasPrefix(sort, "-"), nil
+}
+
+func encodeCursor(image UserImages, filter PhotoFilter) string {
+	b, _ := json.Marshal(photoCursor{
+		CreatedAt: image.CreatedAt,
+		ID:        image.ID,
+		Listing:   listingHash(filter),
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns ErrCursorMismatch when the cursor was issued for other filters or another sort
+func decodeCursor(cursor string, filter PhotoFilter) (*photoCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &photoCursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	if c.Listing != listingHash(filter) {
+		return nil, ErrCursorMismatch
+	}
+	return c, nil
+}
+
+// listingHash identifies the listing of the filter by every field but the cursor and the limit,
+// a page size may change between pages
+func listingHash(filter PhotoFilter) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%d\x00%d", listingSort(filter.Sort), filter.Album, filter.Tag,
+		filter.ContentType, filter.From.Unix(), filter.To.Unix())
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// listingSort returns the sort a listing uses, the default one when none is given
+func listingSort(sort string) string {
+	if sort == "" {
+		return defaultPhotoSort
+	}
+	return sort
+}
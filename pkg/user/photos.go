@@ -0,0 +1,112 @@
+package user
+
+import (
+	"context"
+	"time"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// PhotoService lists, purges and reconciles photos and runs their background jobs. It does not depend on
+// the chat, so the worker and reconcile modes start it without the LLM.
+type PhotoService struct {
+	conf    *viper.Viper
+	log     *logrus.Logger
+	Repo    Repository
+	storage *storage.Service
+	rbac    *rbac.Service
+}
+
+// NewPhotoService returns a photo service object.
+func NewPhotoService(conf *viper.Viper, log *logrus.Logger, Repo Repository, storage *storage.Service, rbac *rbac.Service) *PhotoService {
+	return &PhotoService{
+		conf:    conf,
+		log:     log,
+		Repo:    Repo,
+		storage: storage,
+		rbac:    rbac,
+	}
+}
+
+// ListPhotos returns one page of the user's active photos matching the filter
+func (s *PhotoService) ListPhotos(ctx context.Context, userID int, filter PhotoFilter) (*PhotoPage, error) {
+	if _, _, err := parseSort(filter.Sort); err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageLimit
+	}
+	if filter.Limit > maxPageLimit {
+		filter.Limit = maxPageLimit
+	}
+	after, err := decodeCursor(filter.Cursor, filter)
+	if err != nil {
+		return nil, err
+	}
+	userImages, err := s.Repo.retrievePhotoPage(ctx, userID, filter, after)
+	if err != nil {
+		return nil, err
+	}
+	page := &PhotoPage{Photos: userImages, Limit: filter.Limit}
+	if len(userImages) > filter.Limit {
+		page.Photos = userImages[:filter.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(page.Photos[len(page.Photos)-1], filter)
+	}
+	return page, nil
+}
+
+// FetchPhoto returns a photo without any access check, callers must authorize the access themselves
+func (s *PhotoService) FetchPhoto(ctx context.Context, photoID int) (*UserImages, error) {
+	return s.Repo.fetchPhoto(ctx, photoID)
+}
+
+// TrashRetention returns how long trashed photos are kept before being purged
+func (s *PhotoService) TrashRetention() time.Duration {
+	return time.Duration(s.conf.GetInt(utils.TrashRetentionDays)) * 24 * time.Hour
+}
+
+// PurgeTrash permanently removes the storage objects and rows of every photo
+// that has been in the trash for longer than the retention period.
+// A photo whose objects can not be deleted is logged and skipped, the next run tries it again.
+// It returns the number of photos purged and skipped.
+func (s *PhotoService) PurgeTrash(ctx context.Context) (purged, failed int, err error) {
+	before := time.Now().Add(-s.TrashRetention())
+	afterID := 0
+	for {
+		userImages, err := s.Repo.retrieveExpiredTrash(ctx, before, afterID, purgeBatchSize)
+		if err != nil {
+			return purged, failed, err
+		}
+		for _, image := range userImages {
+			afterID = image.ID
+			if err := s.deletePhotoObjects(ctx, image); err != nil {
+				s.log.WithField("photo_id", image.ID).Error("failed to purge photo: " + err.Error())
+				failed++
+				continue
+			}
+			if err := s.Repo.deletePhoto(ctx, image.ID); err != nil {
+				return purged, failed, err
+			}
+			purged++
+		}
+		if len(userImages) < purgeBatchSize {
+			return purged, failed, nil
+		}
+	}
+}
+
+// deletePhotoObjects deletes the photo and its thumbnail from storage
+func (s *PhotoService) deletePhotoObjects(ctx context.Context, image UserImages) error {
+	if err := s.storage.Delete(ctx, s.storage.KeyFromURL(image.Url)); err != nil {
+		return err
+	}
+	if image.ThumbnailUrl != "" {
+		return s.storage.Delete(ctx, s.storage.KeyFromURL(image.ThumbnailUrl))
+	}
+	return nil
+}
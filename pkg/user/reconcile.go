@@ -0,0 +1,160 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"time"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/utils"
+)
+
+const (
+	JobReconcile = "storage.reconcile"
+
+	reconcileBatchSize = 1000
+	// maxReportedItems caps the lists in a report, the counts are always complete
+	maxReportedItems = 100
+)
+
+// unmanagedPrefixes are bucket prefixes whose objects have no user_images row by design
+var unmanagedPrefixes = []string{"exports/"}
+
+type (
+	// ReconcileOptions controls what Reconcile is allowed to change
+	ReconcileOptions struct {
+		// Fix deletes orphaned objects and deactivates dangling rows, otherwise they are only reported
+		Fix bool
+		// GracePeriod protects objects and rows younger than it, they may belong to an upload in progress
+		GracePeriod time.Duration
+	}
+	// ReconcileReport lists the mismatches between the bucket and the user_images table
+	ReconcileReport struct {
+		StartedAt        time.Time       `json:"started_at"`
+		FinishedAt       time.Time       `json:"finished_at"`
+		Fix              bool            `json:"fix"`
+		ObjectsScanned   int             `json:"objects_scanned"`
+		RowsScanned      int             `json:"rows_scanned"`
+		OrphanedObjects  int             `json:"orphaned_objects"`
+		OrphanedBytes    int64           `json:"orphaned_bytes"`
+		DanglingRows     int             `json:"dangling_rows"`
+		DeletedObjects   int             `json:"deleted_objects"`
+		DeactivatedRows  int             `json:"deactivated_rows"`
+		OrphanedExamples []string        `json:"orphaned_examples"`
+		DanglingExamples []DanglingPhoto `json:"dangling_examples"`
+	}
+	DanglingPhoto struct {
+		ID       int    `json:"id"`
+		UserID   int    `json:"user_id"`
+		Key      string `json:"key"`
+		IsActive bool   `json:"is_active"`
+	}
+)
+
+// Reconcile compares the objects in the bucket with the user_images rows.
+// Objects no row points to are orphaned, active rows whose object is missing are dangling.
+func (s *PhotoService) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	report := &ReconcileReport{
+		StartedAt:        time.Now(),
+		Fix:              opts.Fix,
+		OrphanedExamples: []string{},
+		DanglingExamples: []DanglingPhoto{},
+	}
+	cutoff := report.StartedAt.Add(-opts.GracePeriod)
+
+	objects, err := s.storage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	report.ObjectsScanned = len(objects)
+	inBucket := make(map[string]bool, len(objects))
+	for _, o := range objects {
+		inBucket[o.Key] = true
+	}
+
+	referenced := map[string]bool{}
+	dangling := []int{}
+	afterID := 0
+	for {
+		rows, err := s.Repo.retrieveAllPhotos(ctx, afterID, reconcileBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			key := s.storage.KeyFromURL(row.Url)
+			referenced[key] = true
+			if row.ThumbnailUrl != "" {
+				referenced[s.storage.KeyFromURL(row.ThumbnailUrl)] = true
+			}
+			if inBucket[key] {
+				continue
+			}
+			report.DanglingRows++
+			if len(report.DanglingExamples) < maxReportedItems {
+				report.DanglingExamples = append(report.DanglingExamples, DanglingPhoto{
+					ID: row.ID, UserID: row.UserID, Key: key, IsActive: row.IsActive,
+				})
+			}
+			if row.IsActive && row.CreatedAt.Before(cutoff) {
+				dangling = append(dangling, row.ID)
+			}
+		}
+		report.RowsScanned += len(rows)
+		if len(rows) < reconcileBatchSize {
+			break
+		}
+		afterID = rows[len(rows)-1].ID
+	}
+
+	for _, o := range objects {
+		if referenced[o.Key] || isUnmanaged(o.Key) {
+			continue
+		}
+		report.OrphanedObjects++
+		report.OrphanedBytes += o.Size
+		if len(report.OrphanedExamples) < maxReportedItems {
+			report.OrphanedExamples = append(report.OrphanedExamples, o.Key)
+		}
+		if opts.Fix && o.LastModified.Before(cutoff) {
+			if err := s.storage.Delete(ctx, o.Key); err != nil {
+				return nil, err
+			}
+			report.DeletedObjects++
+		}
+	}
+
+	if opts.Fix && len(dangling) > 0 {
+		if err := s.Repo.deactivatePhotos(ctx, dangling); err != nil {
+			return nil, err
+		}
+		report.DeactivatedRows = len(dangling)
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// ReconcileOptionsFromConfig reads the reconcile_fix and reconcile_grace_period settings
+func (s *PhotoService) ReconcileOptionsFromConfig() ReconcileOptions {
+	return ReconcileOptions{
+		Fix:         s.conf.GetBool(utils.ReconcileFix),
+		GracePeriod: s.conf.GetDuration(utils.ReconcileGracePeriod),
+	}
+}
+
+func (s *PhotoService) reconcileJob(ctx context.Context, job queue.Job) error {
+	report, err := s.Reconcile(ctx, s.ReconcileOptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	s.log.WithField("report", report).Info("storage reconciled")
+	return nil
+}
+
+func isUnmanaged(key string) bool {
+	for _, prefix := range unmanagedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
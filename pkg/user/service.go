@@ -7,7 +7,17 @@ import (
 	"fmt"
 	"mime/multipart"
 	"time"
+	"uber_fx_init_folder_structure/pkg/apikey"
 	"uber_fx_init_folder_structure/pkg/cache"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/experiment"
+	"uber_fx_init_folder_structure/pkg/llm"
+	"uber_fx_init_folder_structure/pkg/memory"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/pkg/queue"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/storage"
+	"uber_fx_init_folder_structure/pkg/usage"
 	"uber_fx_init_folder_structure/utils"
 	"uber_fx_init_folder_structure/utils/bot"
 
@@ -22,11 +32,28 @@ import (
 )
 
 type Service struct {
+	// the photo service answers the photo listing, permission and trash calls of the chat and handlers
+	*PhotoService
 	conf     *viper.Viper
 	log      *logrus.Logger
 	Repo     Repository
 	s3Config *AWSS3Config
-	cache    *cache.Service
+	storage  *storage.Service
+	queue    *queue.Service
+	rbac     *rbac.Service
+	usage    *usage.Service
+	chat     llm.ChatModel
+	profiles *llm.Profiles
+	clock    llm.Clock
+	prompt   *prompt.Service
+	// experiment assigns chat sessions to variants, conversation stores their messages
+	experiment   *experiment.Service
+	conversation *conversation.Service
+	// memory holds the facts the bot remembers about users
+	memory *memory.Service
+	// cache holds the pending and active account switches of chat sessions
+	cache *cache.Service
+	tools map[string]registeredTool
 }
 
 type AWSS3Config struct {
@@ -37,7 +64,7 @@ type AWSS3Config struct {
 }
 
 // NewService returns a user service object.
-func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, cache *cache.Service) *Service {
+func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, photos *PhotoService, storage *storage.Service, queue *queue.Service, rbac *rbac.Service, usage *usage.Service, chat llm.ChatModel, profiles *llm.Profiles, clock llm.Clock, prompt *prompt.Service, experiment *experiment.Service, conversation *conversation.Service, memory *memory.Service, cache *cache.Service) *Service {
 	s3Config := AWSS3Config{
 		AccessKeyID:     conf.GetString(utils.AccessKeyEnv),
 		SecretAccessKey: conf.GetString(utils.SecretAccessKey),
@@ -45,11 +72,24 @@ func NewService(conf *viper.Viper, log *logrus.Logger, Repo Repository, cache *c
 		Bucket:          conf.GetString(utils.BucketName),
 	}
 	return &Service{
-		s3Config: &s3Config,
-		conf:     conf,
-		log:      log,
-		Repo:     Repo,
-		cache:    cache,
+		PhotoService: photos,
+		s3Config:     &s3Config,
+		conf:         conf,
+		log:          log,
+		Repo:         Repo,
+		storage:      storage,
+		queue:        queue,
+		rbac:         rbac,
+		usage:        usage,
+		chat:         chat,
+		profiles:     profiles,
+		clock:        clock,
+		prompt:       prompt,
+		experiment:   experiment,
+		conversation: conversation,
+		memory:       memory,
+		cache:        cache,
+		tools:        map[string]registeredTool{},
 	}
 }
 
@@ -59,6 +99,11 @@ func (s *Service) UpsertUserRegistration(ctx context.Context, user *User) error
 func (s *Service) FetchUserByUsername(ctx context.Context, username string) (*User, error) {
 	return s.Repo.fetchUserByUsername(ctx, username)
 }
+
+func (s *Service) FetchUserByID(ctx context.Context, id int) (*User, error) {
+	return s.Repo.fetchUserByID(ctx, id)
+}
+
 func (s *Service) UserUploadPhoto(ctx context.Context, user UserImages, file multipart.File, fileName, contentType string, sess *session.Session) error {
 	uploader := s3manager.NewUploader(sess)
 	up, err := uploader.Upload(&s3manager.UploadInput{
@@ -77,46 +122,107 @@ func (s *Service) UserUploadPhoto(ctx context.Context, user UserImages, file mul
 
 	filepath := "https://" + s.s3Config.Bucket + "." + "s3-" + s.s3Config.Region + ".amazonaws.com/" + fileName
 	user.Url = filepath
-	return s.Repo.userUploadPhoto(ctx, user)
+	user.ContentType = contentType
+	user.IsActive = true
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	if err := s.Repo.userUploadPhoto(ctx, &user); err != nil {
+		return err
+	}
+
+	// the upload has succeeded even if the photo cannot be post-processed
+	for _, jobType := range []string{JobThumbnail, JobExif} {
+		if _, err := s.queue.Enqueue(ctx, jobType, photoJob{PhotoID: user.ID}); err != nil {
+			s.log.WithField("photo_id", user.ID).Error("failed to enqueue " + jobType + ": " + err.Error())
+		}
+	}
+	return nil
 }
 
-func (s *Service) ProcessMessage(ctx context.Context, message string) (string, error) {
-	client := openai.NewClient(s.conf.GetString("OPEN_AI_API_KEY"))
-	t := s.CustomFunctionOpenAiParams()
+// ProcessMessage answers a chat message of the principal, every tool acts on behalf of the principal.
+// The tokens used are recorded for the session, once the principal's budget is spent the bot declines to answer.
+// The earlier turns of the session are sent with the message, summarized once they exceed the context budget.
+// The message, the tool calls and the answer are stored with the prompt version and the experiment variant
+// that answered, the reply has the ID of the stored answer so that the user can rate it.
+// A signed in user may switch the session to another account with its password, the message carrying the
+// password is checked by switchAccount and never reaches the model, the history or the logs.
+func (s *Service) ProcessMessage(ctx context.Context, principal *User, session Session, message string) (Reply, error) {
+	signedIn := principal
+	if !session.APIKey {
+		if reply, ok, err := s.switchAccount(ctx, signedIn, session, message); ok {
+			return reply, err
+		}
+		principal = s.chatIdentity(ctx, signedIn, session)
+	}
+	switch err := s.usage.CheckBudget(ctx, principal.ID); {
+	case err == usage.ErrDailyBudget:
+		return Reply{Content: dailyBudgetResp}, nil
+	case err == usage.ErrMonthlyBudget:
+		return Reply{Content: monthlyBudgetResp}, nil
+	case err != nil:
+		return Reply{}, err
+	}
 
-	dialogue := bot.Dialogue(message)
-	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT3Dot5Turbo,
-		// MaxTokens:   50,
-		Messages:    dialogue,
-		Temperature: 2,
-		Tools:       t,
-		TopP:        0.01,
-	})
+	t := s.CustomFunctionOpenAiParams(ctx, principal, session)
+
+	assignment := s.assign(ctx, session)
+	persona := s.persona(ctx, principal, session, assignment, message)
+	history := s.history(ctx, principal, session, assignment, persona.Messages, message, t)
+	dialogue := bot.Dialogue(principal.Username, persona.Messages, history, message)
+	// the messages of this turn, stored with the answer
+	turn := len(dialogue) - 1
+	req := openai.ChatCompletionRequest{Messages: dialogue, Tools: t}
+	s.profile(ctx, principal, llm.StageMain, assignment).Apply(&req)
+	resp, err := s.chat.CreateChatCompletion(ctx, req)
+	if errors.Is(err, llm.ErrUnavailable) {
+		s.log.WithField("user_id", principal.ID).Error(err.Error())
+		return Reply{Content: degradedResp}, nil
+	}
 	if err != nil || len(resp.Choices) != 1 {
-		return "", fmt.Errorf("completion error: %v len(choices): %v", err, len(resp.Choices))
+		return Reply{}, fmt.Errorf("completion error: %v len(choices): %v", err, len(resp.Choices))
 	}
+	s.recordUsage(ctx, principal, session.ID, assignment, resp)
 
 	msg := resp.Choices[0].Message
 	if len(msg.ToolCalls) > 0 {
 		dialogue = append(dialogue, msg)
 		call := msg.ToolCalls[0]
-		s.log.Infof("OpenAI called us back wanting to invoke our function '%v' with params '%v'\n",
-			call.Function.Name, call.Function.Arguments)
+		// the arguments are not logged, they carry the user's memories, emails and the like
+		s.log.WithField("user_id", principal.ID).Infof("the model called the tool %s", call.Function.Name)
 
-		var user User
-		if err := json.Unmarshal([]byte(call.Function.Arguments), &user); err != nil {
-			return "", err
+		var args ToolArgs
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			return Reply{}, err
 		}
 
 		var toolResp string
-		switch call.Function.Name {
-		case "CreateUsername":
-			toolResp = s.CreateUsername(user)
-		case "FetchPhotos":
-			toolResp = fmt.Sprint(s.FetchPhotos(user))
+		switch {
+		case !offered(t, call.Function.Name):
+			// the tool was not offered to the session, the model made the call up
+			toolResp = forbiddenToolResp
+		case call.Function.Name == "FetchPhotos":
+			toolResp = fmt.Sprint(s.FetchPhotos(ctx, principal, args.Username, args.Album))
+		case call.Function.Name == "TrashPhoto":
+			toolResp = s.TrashPhotoTool(ctx, principal, args.PhotoID)
+		case call.Function.Name == "ListTrash":
+			toolResp = fmt.Sprint(s.ListTrashTool(ctx, principal))
+		case call.Function.Name == "RestorePhoto":
+			toolResp = s.RestorePhotoTool(ctx, principal, args.PhotoID)
+		case call.Function.Name == "SwitchAccount":
+			toolResp = s.switchAccountTool(ctx, signedIn, session, args.Username)
 		default:
-			return "", fmt.Errorf("unsupported tool call: %s", call.Function.Name)
+			tool, ok := s.tools[call.Function.Name]
+			if !ok {
+				return Reply{}, fmt.Errorf("unsupported tool call: %s", call.Function.Name)
+			}
+			toolResp, err = tool.fn(ctx, principal, json.RawMessage(call.Function.Arguments))
+			if errors.Is(err, ErrForbidden) {
+				toolResp, err = forbiddenToolResp, nil
+			}
+			if err != nil {
+				s.log.WithField("tool", call.Function.Name).Error(err.Error())
+				toolResp = "something went wrong please try again"
+			}
 		}
 
 		dialogue = append(dialogue, openai.ChatCompletionMessage{
@@ -126,106 +232,395 @@ func (s *Service) ProcessMessage(ctx context.Context, message string) (string, e
 			ToolCallID: call.ID,
 		})
 
-		resp, err = client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-			Model:    openai.GPT3Dot5Turbo,
-			Messages: dialogue,
-			Tools:    t,
-		})
+		req = openai.ChatCompletionRequest{Messages: dialogue, Tools: t}
+		s.profile(ctx, principal, llm.StageFollowUp, assignment).Apply(&req)
+		resp, err = s.chat.CreateChatCompletion(ctx, req)
+		if errors.Is(err, llm.ErrUnavailable) {
+			s.log.WithField("user_id", principal.ID).Error(err.Error())
+			return Reply{Content: degradedResp}, nil
+		}
 		if err != nil || len(resp.Choices) != 1 {
-			return "", fmt.Errorf("2nd completion error: %v len(choices): %v", err, len(resp.Choices))
+			return Reply{}, fmt.Errorf("2nd completion error: %v len(choices): %v", err, len(resp.Choices))
 		}
-		return resp.Choices[0].Message.Content, nil
+		s.recordUsage(ctx, principal, session.ID, assignment, resp)
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	answer := resp.Choices[0].Message
+	dialogue = append(dialogue, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: answer.Content})
+	reply := Reply{Content: answer.Content}
+	reply.ID = s.storeMessages(ctx, principal, session, persona.Version, assignment, dialogue[turn:])
+	return reply, nil
 }
 
-// Function to call the API to retrieve photos based on username
-func (s *Service) RetrievePhotos(ctx context.Context, username string) ([]string, error) {
-	user, err := s.FetchUserByUsername(ctx, username)
+// assign returns the experiment variant of the session, nil when the session takes part in no experiment
+func (s *Service) assign(ctx context.Context, session Session) *experiment.Assignment {
+	assignment, err := s.experiment.Assign(ctx, session.ID)
 	if err != nil {
-		if err == _pg.ErrNoRows {
-			return []string{"username not found ask to create a new user to upload photos"}, nil
+		s.log.WithField("session_id", session.ID).Error("failed to assign an experiment variant: " + err.Error())
+	}
+	return assignment
+}
+
+// storeMessages stores the messages of a turn, the user's message, the tool calls and results and the answer,
+// and returns the ID of the answer, 0 when they can not be stored as the answer is still given
+func (s *Service) storeMessages(ctx context.Context, principal *User, session Session, promptVersion int, assignment *experiment.Assignment, turn []openai.ChatCompletionMessage) int {
+	messages := []*conversation.Message{}
+	for _, message := range turn {
+		m := &conversation.Message{Role: message.Role, Content: message.Content}
+		switch {
+		case message.Role == openai.ChatMessageRoleAssistant && len(message.ToolCalls) > 0:
+			m.Role, m.ToolCalls = conversation.RoleToolCall, message.ToolCalls
+		case message.Role == openai.ChatMessageRoleTool:
+			m.Role, m.Name, m.ToolCallID = conversation.RoleTool, message.Name, message.ToolCallID
 		}
-		return nil, err
+		messages = append(messages, m)
+	}
+	for _, m := range messages {
+		m.UserID, m.SessionID, m.PromptVersion = principal.ID, session.ID, promptVersion
+		if assignment != nil {
+			m.ExperimentID, m.Variant = assignment.ExperimentID, assignment.Variant.Name
+		}
+	}
+	if err := s.conversation.Append(ctx, messages...); err != nil {
+		s.log.WithField("user_id", principal.ID).Error("failed to store chat messages: " + err.Error())
+		return 0
+	}
+	return messages[len(messages)-1].ID
+}
+
+// persona returns the system prompt rendered for the principal, the experiment variant's version or the
+// active one. The bot keeps answering with the built-in prompt when it can not be loaded.
+func (s *Service) persona(ctx context.Context, principal *User, session Session, assignment *experiment.Assignment, message string) *prompt.Prompt {
+	version := 0
+	if assignment != nil {
+		version = assignment.Variant.PromptVersion
 	}
-	userImages, err := s.Repo.retrievePhotos(ctx, user.ID)
+	data := s.promptContext(ctx, principal, session, message)
+	rendered, err := s.prompt.Render(ctx, prompt.System, version, data)
+	if err == nil {
+		return rendered
+	}
+	s.log.WithField("prompt", prompt.System).Error("failed to load the prompt: " + err.Error())
+	messages, err := prompt.Render(prompt.Defaults[prompt.System], data)
 	if err != nil {
-		if err == _pg.ErrNoRows {
-			return []string{"photos not found ask to upload photos"}, nil
+		// the defaults are validated at startup
+		s.log.WithField("prompt", prompt.System).Error("failed to render the built-in prompt: " + err.Error())
+	}
+	return &prompt.Prompt{Name: prompt.System, Messages: messages}
+}
+
+// promptContext returns what the system prompt is rendered with for the message, details that can not be
+// loaded are left empty rather than failing the message
+func (s *Service) promptContext(ctx context.Context, principal *User, session Session, message string) prompt.Context {
+	data := prompt.Context{
+		User: prompt.UserContext{
+			Username:  principal.Username,
+			FirstName: principal.FirstName,
+			LastName:  principal.LastName,
+			Email:     principal.Email,
+			SignedIn:  !session.APIKey,
+		},
+		Session:  prompt.SessionContext{ID: session.ID},
+		Photos:   prompt.PhotoContext{Albums: []string{}},
+		Memories: []string{},
+		Locale:   session.Locale,
+		Now:      s.clock(),
+	}
+	if data.Locale == "" {
+		data.Locale = s.conf.GetString(utils.DefaultLocale)
+	}
+	stats, err := s.Repo.photoStats(ctx, principal.ID)
+	if err != nil {
+		s.log.WithField("user_id", principal.ID).Error("failed to count photos: " + err.Error())
+	} else {
+		data.Photos = prompt.PhotoContext{Count: stats.Count, Trashed: stats.Trashed, Albums: stats.Albums}
+	}
+	if data.Session.Tokens, err = s.usage.SessionTokens(ctx, principal.ID, session.ID); err != nil {
+		s.log.WithField("user_id", principal.ID).Error("failed to sum session tokens: " + err.Error())
+	}
+	memories, err := s.memory.Relevant(ctx, principal.ID, message)
+	if err != nil {
+		s.log.WithField("user_id", principal.ID).Error("failed to load memories: " + err.Error())
+	}
+	for _, m := range memories {
+		data.Memories = append(data.Memories, m.Content)
+	}
+	return data
+}
+
+// PreviewMessage answers the message with the prompt messages instead of the active system prompt,
+// without tools so that a preview never acts on the principal's photos. The messages are rendered
+// for the principal, it returns prompt.ErrInvalidTemplate when they are not valid templates.
+func (s *Service) PreviewMessage(ctx context.Context, principal *User, session Session, messages []string, message string) (string, []openai.ChatCompletionMessage, error) {
+	persona, err := prompt.Render(messages, s.promptContext(ctx, principal, session, message))
+	if err != nil {
+		return "", nil, err
+	}
+	dialogue := bot.Dialogue(principal.Username, persona, nil, message)
+	req := openai.ChatCompletionRequest{Messages: dialogue}
+	s.profile(ctx, principal, llm.StageMain, nil).Apply(&req)
+	resp, err := s.chat.CreateChatCompletion(ctx, req)
+	if err != nil || len(resp.Choices) != 1 {
+		return "", dialogue, fmt.Errorf("completion error: %v len(choices): %v", err, len(resp.Choices))
+	}
+	s.recordUsage(ctx, principal, session.ID, nil, resp)
+	return resp.Choices[0].Message.Content, dialogue, nil
+}
+
+// profile returns the model profile of the stage for the principal's roles, or the profile of
+// the experiment variant when it sets one
+func (s *Service) profile(ctx context.Context, principal *User, stage string, assignment *experiment.Assignment) llm.Profile {
+	if assignment != nil && assignment.Variant.Profile != "" && stage != llm.StageSummary {
+		if profile, ok := s.profiles.Named(assignment.Variant.Profile); ok {
+			return profile
 		}
+	}
+	if !s.profiles.HasRoleOverrides() {
+		return s.profiles.For(stage, nil)
+	}
+	roles, err := s.rbac.UserRoles(ctx, principal.ID)
+	if err != nil {
+		s.log.WithField("user_id", principal.ID).Error("rbac: " + err.Error())
+	}
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return s.profiles.For(stage, names)
+}
+
+// recordUsage stores the tokens of a completion, the answer is still given when they can not be stored
+func (s *Service) recordUsage(ctx context.Context, principal *User, sessionID string, assignment *experiment.Assignment, resp openai.ChatCompletionResponse) {
+	record := &usage.Record{
+		UserID:    principal.ID,
+		SessionID: sessionID,
+		// the model that answered, which is not the configured one after a fallback
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}
+	if record.Model == "" {
+		record.Model = s.chat.Model()
+	}
+	if assignment != nil {
+		record.ExperimentID, record.Variant = assignment.ExperimentID, assignment.Variant.Name
+	}
+	if err := s.usage.Record(ctx, record); err != nil {
+		s.log.WithField("user_id", principal.ID).Error("failed to record llm usage: " + err.Error())
+	}
+}
+
+// RetrievePhotos returns the photos of the owner as "id: url", only the album when it is set
+func (s *Service) RetrievePhotos(ctx context.Context, ownerID int, album string) ([]string, error) {
+	userImages, err := s.Repo.retrievePhotos(ctx, ownerID)
+	if err != nil {
 		return nil, err
 	}
 	arr := []string{}
 	for _, image := range userImages {
-		arr = append(arr, image.Url)
+		if album != "" && image.Album != album {
+			continue
+		}
+		arr = append(arr, fmt.Sprintf("%d: %s", image.ID, image.Url))
+	}
+	if len(arr) == 0 {
+		return []string{"photos not found ask to upload photos"}, nil
 	}
 	return arr, nil
 }
 
-func (s *Service) CustomFunctionOpenAiParams() []openai.Tool {
+// TrashPhoto moves a photo into its owner's trash bin.
+// It stays restorable until the trash retention period has passed.
+func (s *Service) TrashPhoto(ctx context.Context, principal *User, photoID int) error {
+	photo, err := s.fetchPhotoFor(ctx, principal, photoID, PermissionWrite)
+	if err != nil {
+		return err
+	}
+	return s.Repo.trashPhoto(ctx, photo.UserID, photoID)
+}
+
+// RetrieveTrash returns the photos in the user's trash bin, most recently deleted first
+func (s *Service) RetrieveTrash(ctx context.Context, userID int) ([]UserImages, error) {
+	return s.Repo.retrieveTrash(ctx, userID)
+}
+
+// RestorePhoto moves a photo out of its owner's trash bin
+func (s *Service) RestorePhoto(ctx context.Context, principal *User, photoID int) error {
+	photo, err := s.fetchPhotoFor(ctx, principal, photoID, PermissionWrite)
+	if err != nil {
+		return err
+	}
+	return s.Repo.restorePhoto(ctx, photo.UserID, photoID)
+}
+
+// CustomFunctionOpenAiParams returns the tools offered to the principal in the session, chats through
+// an API key are only offered the tools its scopes allow
+func (s *Service) CustomFunctionOpenAiParams(ctx context.Context, principal *User, session Session) []openai.Tool {
 	usernameParam := jsonschema.Definition{
 		Type:        jsonschema.String,
-		Description: "the username is  e.g., user0512",
+		Description: "the username whose photos were shared with the user e.g., user0512, leave it empty for the user's own photos",
+	}
+	albumParam := jsonschema.Definition{
+		Type:        jsonschema.String,
+		Description: "only fetch the photos of this album e.g., goa",
+	}
+	fetchPhotosFunction := openai.FunctionDefinition{
+		Name:        "FetchPhotos",
+		Description: "fetches the photos of the user, or the photos another user shared with them",
+		Parameters: jsonschema.Definition{
+			Type:       jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{"username": usernameParam, "album": albumParam},
+		},
 	}
-	createUsernameFunction := openai.FunctionDefinition{
-		Name:        "CreateUsername",
-		Description: "creates or for uploding photos this will be used",
+
+	photoIDParam := jsonschema.Definition{
+		Type:        jsonschema.Integer,
+		Description: "the photo id as listed by FetchPhotos or ListTrash e.g., 42",
+	}
+	trashPhotoFunction := openai.FunctionDefinition{
+		Name:        "TrashPhoto",
+		Description: "deletes a photo by moving it to the trash bin, it can be restored until the trash is purged",
 		Parameters: jsonschema.Definition{
 			Type:       jsonschema.Object,
-			Properties: map[string]jsonschema.Definition{"username": usernameParam},
-			Required:   []string{"username"},
+			Properties: map[string]jsonschema.Definition{"photo_id": photoIDParam},
+			Required:   []string{"photo_id"},
 		},
 	}
-	fetchPhotosFunction := openai.FunctionDefinition{
-		Name:        "FetchPhotos",
-		Description: "fetches photos for a given username",
+	listTrashFunction := openai.FunctionDefinition{
+		Name:        "ListTrash",
+		Description: "lists the deleted photos in the user's trash bin",
 		Parameters: jsonschema.Definition{
 			Type:       jsonschema.Object,
-			Properties: map[string]jsonschema.Definition{"username": usernameParam},
-			Required:   []string{"username"},
+			Properties: map[string]jsonschema.Definition{},
+		},
+	}
+	restorePhotoFunction := openai.FunctionDefinition{
+		Name:        "RestorePhoto",
+		Description: "restores a photo from the trash bin",
+		Parameters: jsonschema.Definition{
+			Type:       jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{"photo_id": photoIDParam},
+			Required:   []string{"photo_id"},
+		},
+	}
+
+	switchAccountFunction := openai.FunctionDefinition{
+		Name:        "SwitchAccount",
+		Description: "switches the chat to another account of the user, the user is asked for its password afterwards, pass their own username to switch back",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{"username": {
+				Type:        jsonschema.String,
+				Description: "the username of the account to switch to e.g., user0512",
+			}},
+			Required: []string{"username"},
 		},
 	}
 
-	return []openai.Tool{
-		{Type: openai.ToolTypeFunction, Function: &createUsernameFunction},
-		{Type: openai.ToolTypeFunction, Function: &fetchPhotosFunction},
+	t := []openai.Tool{}
+	if !session.APIKey {
+		// an API key acts on its owner's account only
+		t = append(t, openai.Tool{Type: openai.ToolTypeFunction, Function: &switchAccountFunction})
 	}
+	if session.HasScope(apikey.ScopePhotosRead) {
+		t = append(t,
+			openai.Tool{Type: openai.ToolTypeFunction, Function: &fetchPhotosFunction},
+			openai.Tool{Type: openai.ToolTypeFunction, Function: &listTrashFunction},
+		)
+	}
+	if session.HasScope(apikey.ScopePhotosWrite) {
+		t = append(t,
+			openai.Tool{Type: openai.ToolTypeFunction, Function: &trashPhotoFunction},
+			openai.Tool{Type: openai.ToolTypeFunction, Function: &restorePhotoFunction},
+		)
+	}
+	return append(t, s.registeredTools(ctx, principal, session)...)
 }
 
-// GetCurrentWeather returns the current weather in a given location
-func (s *Service) CreateUsername(user User) string {
-	ctx := context.Background()
+// forbiddenToolResp tells the model that a tool was denied without revealing whether the resource exists
+const forbiddenToolResp = "the user does not have access to this, tell them it is not theirs or was not shared with them"
 
-	userdata, err := s.FetchUserByUsername(ctx, user.Username)
-	if err != nil && err != _pg.ErrNoRows {
-		return "please try again later"
-	}
-	if userdata.Username == "" {
-		err := s.UpsertUserRegistration(ctx, &user)
-		s.log.Info("Creating new user")
-		if err != nil {
-			return "unable to create new user ask to try again"
+// answers given instead of calling the model once the principal's token budget is spent
+const (
+	dailyBudgetResp   = "You have used up today's chat allowance, it resets at midnight UTC. Please come back tomorrow."
+	monthlyBudgetResp = "You have used up this month's chat allowance, it resets on the first of next month."
+)
+
+// wrongPasswordResp is answered when the password sent for an account switch does not match
+const wrongPasswordResp = "That password is not right, the chat stays on your account. Ask me again to switch accounts."
+
+// degradedResp is answered when no chat model could be reached
+const degradedResp = "Sorry, I can not think straight right now. Please try again in a minute."
+
+// FetchPhotos lists the photos of the principal, or of username when they shared them with the principal
+func (s *Service) FetchPhotos(ctx context.Context, principal *User, username, album string) []string {
+	owner := principal
+	if username != "" && username != principal.Username {
+		var err error
+		owner, err = s.FetchUserByUsername(ctx, username)
+		if err == _pg.ErrNoRows {
+			return []string{forbiddenToolResp}
 		}
-		err = s.cache.Set("username", user.Username, 5*time.Minute)
 		if err != nil {
-			return "something went wrong please try again"
+			return []string{}
 		}
-		return user.Username
 	}
-	err = s.cache.Set("username", userdata.Username, 5*time.Minute)
+	err := s.Authorize(ctx, principal, owner.ID, album, PermissionRead)
+	if err == ErrForbidden {
+		return []string{forbiddenToolResp}
+	}
+	if err != nil {
+		return []string{}
+	}
+	imagedata, err := s.RetrievePhotos(ctx, owner.ID, album)
+	if err != nil {
+		return []string{}
+	}
+	return imagedata
+}
+
+// TrashPhotoTool moves a photo to the trash bin
+func (s *Service) TrashPhotoTool(ctx context.Context, principal *User, photoID int) string {
+	err := s.TrashPhoto(ctx, principal, photoID)
+	if err == _pg.ErrNoRows {
+		return "photo not found ask to check the photo id"
+	}
+	if err == ErrForbidden {
+		return forbiddenToolResp
+	}
 	if err != nil {
-		return "something went wrong please try again"
+		return "unable to delete the photo ask to try again"
 	}
-	return "greet the user welcome back ,if you want to upload photos you can do so by clicking on the upload button in the chat box" + userdata.Username
+	return fmt.Sprintf("photo moved to trash, it can be restored within %d days", s.conf.GetInt(utils.TrashRetentionDays))
 }
 
-// GetCurrentWeather returns the current weather in a given location
-func (s *Service) FetchPhotos(user User) []string {
-	ctx := context.Background()
-	imagedata, err := s.RetrievePhotos(ctx, user.Username)
+// ListTrashTool lists the principal's trash bin
+func (s *Service) ListTrashTool(ctx context.Context, principal *User) []string {
+	userImages, err := s.RetrieveTrash(ctx, principal.ID)
 	if err != nil {
 		return []string{}
 	}
-	return imagedata
+	if len(userImages) == 0 {
+		return []string{"trash is empty"}
+	}
+	arr := []string{}
+	for _, image := range userImages {
+		arr = append(arr, fmt.Sprintf("%d: %s (deleted %s)", image.ID, image.Url, image.DeletedAt.Format(time.RFC1123)))
+	}
+	return arr
+}
+
+// RestorePhotoTool restores a photo from the trash bin
+func (s *Service) RestorePhotoTool(ctx context.Context, principal *User, photoID int) string {
+	err := s.RestorePhoto(ctx, principal, photoID)
+	if err == _pg.ErrNoRows {
+		return "photo not found in trash ask to check the photo id"
+	}
+	if err == ErrForbidden {
+		return forbiddenToolResp
+	}
+	if err != nil {
+		return "unable to restore the photo ask to try again"
+	}
+	return "photo restored"
 }
@@ -0,0 +1,122 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	_pg "github.com/go-pg/pg/v10"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+	// usernameAttempts is how many random suffixes are tried when the username is taken
+	usernameAttempts = 5
+)
+
+var (
+	ErrNoUsername = errors.New("could not pick a free username")
+	ErrSSOLinked  = errors.New("the account or the single sign-on identity is linked already")
+)
+
+// SignInSSO returns the user of a verified single sign-on identity, creating it on its first sign in.
+// Existing accounts are never picked by email, the emails of local accounts are not verified,
+// they are linked by their signed in user with LinkSSO.
+func (s *Service) SignInSSO(ctx context.Context, identity SSOIdentity) (*User, error) {
+	user, err := s.Repo.fetchUserBySSO(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if err != _pg.ErrNoRows {
+		return nil, err
+	}
+
+	now := time.Now()
+	user = &User{
+		FirstName:   identity.FirstName,
+		LastName:    identity.LastName,
+		OIDCIssuer:  identity.Issuer,
+		OIDCSubject: identity.Subject,
+		IsActive:    true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if identity.EmailVerified {
+		user.Email = identity.Email
+	}
+	base := usernameFromIdentity(identity)
+	for i := 0; i < usernameAttempts; i++ {
+		user.Username = base
+		if i > 0 {
+			user.Username = withSuffix(base)
+		}
+		ok, err := s.Repo.insertUser(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return user, nil
+		}
+	}
+	return nil, ErrNoUsername
+}
+
+// LinkSSO links the single sign-on identity to the signed in user, who can sign in with either afterwards.
+// It returns ErrSSOLinked when the user or the identity is linked to another account already.
+func (s *Service) LinkSSO(ctx context.Context, userID int, identity SSOIdentity) (*User, error) {
+	linked, err := s.Repo.fetchUserBySSO(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		if linked.ID != userID {
+			return nil, ErrSSOLinked
+		}
+		return linked, nil
+	}
+	if err != _pg.ErrNoRows {
+		return nil, err
+	}
+	ok, err := s.Repo.linkSSO(ctx, userID, identity.Issuer, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSSOLinked
+	}
+	s.log.WithField("user_id", userID).Info("linked single sign-on identity")
+	return s.FetchUserByID(ctx, userID)
+}
+
+// usernameFromIdentity derives a username valid for registration from the preferred username or email
+func usernameFromIdentity(identity SSOIdentity) string {
+	candidate := identity.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(identity.Email, "@")
+	}
+	b := strings.Builder{}
+	for _, r := range strings.ToLower(candidate) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	username := b.String()
+	if len(username) < minUsernameLength {
+		username = "user" + username
+	}
+	if len(username) > maxUsernameLength-4 {
+		// leaves room for a suffix
+		username = username[:maxUsernameLength-4]
+	}
+	return username
+}
+
+func withSuffix(username string) string {
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return username + "0000"
+	}
+	return fmt.Sprintf("%s%04d", username, n.Int64())
+}
@@ -0,0 +1,72 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"uber_fx_init_folder_structure/utils/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ToolFunc handles a chat tool call of the principal, args are the raw JSON arguments sent by OpenAI.
+// The returned string is passed back to the model as the tool result, returning ErrForbidden
+// tells the model the principal has no access.
+type ToolFunc func(ctx context.Context, principal *User, args json.RawMessage) (string, error)
+
+type registeredTool struct {
+	definition openai.FunctionDefinition
+	fn         ToolFunc
+	// permission is required to use the tool, empty for every user
+	permission types.Permission
+	// scope is the API key scope required to use the tool through a key
+	scope string
+}
+
+// RegisterTool makes a chat tool implemented outside of this package available to the bot.
+// Chats through an API key are only offered the tool when the key has the scope, which is
+// the scope of the routes doing the same. Tools must be registered during app startup,
+// before any message is processed.
+func (s *Service) RegisterTool(definition openai.FunctionDefinition, scope string, fn ToolFunc) {
+	s.tools[definition.Name] = registeredTool{definition: definition, fn: fn, scope: scope}
+}
+
+// RegisterRestrictedTool registers a chat tool that is only offered to signed in users with the permission,
+// like the admin routes it is never offered to chats through an API key
+func (s *Service) RegisterRestrictedTool(definition openai.FunctionDefinition, permission types.Permission, fn ToolFunc) {
+	s.tools[definition.Name] = registeredTool{definition: definition, fn: fn, permission: permission}
+}
+
+// registeredTools returns the OpenAI definitions of the registered tools the principal may use in the session,
+// sorted by name so that every completion request lists the tools in the same order
+func (s *Service) registeredTools(ctx context.Context, principal *User, session Session) []openai.Tool {
+	names := []string{}
+	for name := range s.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t := []openai.Tool{}
+	for _, name := range names {
+		tool := s.tools[name]
+		if tool.permission != "" && (session.APIKey || !s.HasPermission(ctx, principal, tool.permission)) {
+			continue
+		}
+		if tool.scope != "" && !session.HasScope(tool.scope) {
+			continue
+		}
+		definition := tool.definition
+		t = append(t, openai.Tool{Type: openai.ToolTypeFunction, Function: &definition})
+	}
+	return t
+}
+
+// offered reports whether the tool is among the tools offered to the model
+func offered(tools []openai.Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Function != nil && tool.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -6,32 +6,136 @@ import (
 	"go.uber.org/fx"
 )
 
+// JobsModule provides the photo service and registers its jobs, without the chat
+var JobsModule = fx.Options(
+	fx.Provide(
+		NewDBRepository,
+		NewPhotoService,
+	),
+	fx.Invoke(
+		RegisterJobs,
+	),
+)
+
 // Module provides all constructor and invocation methods to facilitate credits module
 var Module = fx.Options(
+	JobsModule,
 	fx.Provide(
-		NewDBRepository,
 		NewService,
 	),
+	fx.Invoke(
+		RegisterAdminTools,
+		RegisterMemoryTools,
+	),
 )
 
 type (
 	// User represents the user entity
 	User struct {
-		tableName struct{}  `pg:"users,discard_unknown_columns"`
-		ID        int       `json:"id" pg:"id,pk"`
-		Username  string    `json:"username" pg:"username,unique"`
-		IsActive  bool      `json:"is_active" pg:"is_active"`
-		CreatedAt time.Time `json:"created_at" pg:"created_at"`
-		UpdatedAt time.Time `json:"updated_at" pg:"updated_at"`
+		tableName    struct{} `pg:"users,discard_unknown_columns"`
+		ID           int      `json:"id" pg:"id,pk"`
+		Username     string   `json:"username" pg:"username,unique"`
+		PasswordHash string   `json:"-" pg:"password_hash"`
+		Email        string   `json:"email,omitempty" pg:"email"`
+		Mobile       string   `json:"mobile,omitempty" pg:"mobile"`
+		FirstName    string   `json:"first_name,omitempty" pg:"first_name"`
+		LastName     string   `json:"last_name,omitempty" pg:"last_name"`
+		// OIDCIssuer and OIDCSubject identify users that sign in through single sign-on
+		OIDCIssuer  string    `json:"-" pg:"oidc_issuer"`
+		OIDCSubject string    `json:"-" pg:"oidc_subject"`
+		IsActive    bool      `json:"is_active" pg:"is_active"`
+		CreatedAt   time.Time `json:"created_at" pg:"created_at"`
+		UpdatedAt   time.Time `json:"updated_at" pg:"updated_at"`
+	}
+	// Grant shares the photos of an owner with another user, all of them or only one album
+	Grant struct {
+		tableName  struct{}  `pg:"photo_grants,alias:photo_grant,discard_unknown_columns"`
+		ID         int       `json:"id" pg:"id,pk"`
+		OwnerID    int       `json:"-" pg:"owner_id"`
+		Owner      *User     `json:"-" pg:"rel:has-one"`
+		GranteeID  int       `json:"-" pg:"grantee_id"`
+		Grantee    *User     `json:"-" pg:"rel:has-one"`
+		Album      string    `json:"album,omitempty" pg:"album"`
+		Permission string    `json:"permission" pg:"permission"`
+		CreatedAt  time.Time `json:"created_at" pg:"created_at"`
+
+		// OwnerUsername and GranteeUsername are set from the relations, other user details stay private
+		OwnerUsername   string `json:"owner" pg:"-"`
+		GranteeUsername string `json:"grantee" pg:"-"`
+	}
+	// SSOIdentity are the claims of a verified ID token
+	SSOIdentity struct {
+		Issuer            string
+		Subject           string
+		Email             string
+		EmailVerified     bool
+		PreferredUsername string
+		FirstName         string
+		LastName          string
 	}
 	UserImages struct {
-		tableName struct{}  `pg:"user_images,discard_unknown_columns"`
-		ID        int       `json:"-" pg:"id,pk"`
-		UserID    int       `json:"-" pg:"user_id"`
-		Url       string    `json:"url" pg:"url"`
-		IsActive  bool      `json:"-" pg:"is_active"`
-		CreatedAt time.Time `json:"-" pg:"created_at"`
-		UpdatedAt time.Time `json:"-" pg:"updated_at"`
+		tableName   struct{} `pg:"user_images,discard_unknown_columns"`
+		ID          int      `json:"id" pg:"id,pk"`
+		UserID      int      `json:"-" pg:"user_id"`
+		Url         string   `json:"url" pg:"url"`
+		ContentType string   `json:"content_type" pg:"content_type"`
+		Album       string   `json:"album" pg:"album"`
+		Tags        []string `json:"tags" pg:"tags,array"`
+		// ThumbnailUrl, TakenAt and Exif are filled in by background jobs after the upload
+		ThumbnailUrl string            `json:"thumbnail_url,omitempty" pg:"thumbnail_url"`
+		TakenAt      time.Time         `json:"taken_at,omitempty" pg:"taken_at"`
+		Exif         map[string]string `json:"exif,omitempty" pg:"exif,type:jsonb"`
+		IsActive     bool              `json:"-" pg:"is_active"`
+		DeletedAt    time.Time         `json:"deleted_at" pg:"deleted_at"`
+		CreatedAt    time.Time         `json:"created_at" pg:"created_at"`
+		UpdatedAt    time.Time         `json:"updated_at" pg:"updated_at"`
+	}
+	// PhotoFilter narrows down and orders a page of a user's photos
+	PhotoFilter struct {
+		From        time.Time
+		To          time.Time
+		Tag         string
+		Album       string
+		ContentType string
+		// Sort is the column to sort by, prefixed with `-` for descending order
+		Sort   string
+		Cursor string
+		Limit  int
+	}
+	// PhotoStats counts the photos of a user
+	PhotoStats struct {
+		Count   int
+		Trashed int
+		Albums  []string
+	}
+	// Session is the chat a message is sent in, a websocket connection or the messages sent with one session_id
+	Session struct {
+		ID string
+		// Locale is the language tag the client asked for
+		Locale string
+		// APIKey is set when the messages are sent with an API key rather than by a signed in user,
+		// Scopes are the key's scopes then
+		APIKey bool
+		Scopes []string
+	}
+	// Reply is the bot's answer to a chat message, ID is the stored answer's and 0 when the
+	// bot declined to answer or it could not be stored
+	Reply struct {
+		ID      int
+		Content string
+	}
+	// PhotoPage is one page of photos and the cursor to fetch the next one
+	PhotoPage struct {
+		Photos     []UserImages
+		NextCursor string
+		HasMore    bool
+		Limit      int
+	}
+	// ToolArgs holds the arguments OpenAI passes when calling one of our chat tools
+	ToolArgs struct {
+		Username string `json:"username"`
+		Album    string `json:"album"`
+		PhotoID  int    `json:"photo_id"`
 	}
 	HistoryLogs struct {
 		ID        int       `json:"id" pg:"id"`
@@ -44,3 +148,16 @@ type (
 )
 
 var UserName string
+
+// HasScope reports whether the session may use tools requiring scope, a signed in user may use every tool
+func (s Session) HasScope(scope string) bool {
+	if !s.APIKey {
+		return true
+	}
+	for _, sc := range s.Scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
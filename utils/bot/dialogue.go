@@ -4,27 +4,24 @@ import (
 	"github.com/sashabaranov/go-openai"
 )
 
-func Dialogue(message string) []openai.ChatCompletionMessage {
-	return []openai.ChatCompletionMessage{
+// Dialogue returns the messages of a chat, the persona messages are sent as system messages
+// and the history of the chat before the message
+func Dialogue(username string, persona []string, history []openai.ChatCompletionMessage, message string) []openai.ChatCompletionMessage {
+	dialogue := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
-			Content: "in starting you will ask for username ?",
+			Content: "the user is signed in as " + username + ", every tool acts on their account, never ask for a password yourself, call SwitchAccount when they want to use another account",
 		},
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: "You can help to upload photos ask the user to give an username click upload button below and upload ?",
-		},
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: "you will ask the user if they want to upload photos or retrieve them ?",
-		},
-		{
+	}
+	for _, content := range persona {
+		dialogue = append(dialogue, openai.ChatCompletionMessage{
 			Role:    openai.ChatMessageRoleSystem,
-			Content: "You are Alexia, a helpful AI assistant",
-		},
-		{
-			Role:    openai.ChatMessageRoleUser,
-			Content: message,
-		},
+			Content: content,
+		})
 	}
+	dialogue = append(dialogue, history...)
+	return append(dialogue, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: message,
+	})
 }
@@ -5,4 +5,78 @@ const (
 	SecretAccessKey = "AWS_SECRET_KEY"
 	Region          = "AWS_REGION"
 	BucketName      = "AWS_BUCKET"
+
+	TrashRetentionDays = "TRASH_RETENTION_DAYS"
+	TrashPurgeInterval = "TRASH_PURGE_INTERVAL"
+
+	ExportLinkExpiry      = "EXPORT_LINK_EXPIRY"
+	ExportCleanupInterval = "EXPORT_CLEANUP_INTERVAL"
+
+	QueueConcurrency       = "QUEUE_CONCURRENCY"
+	QueueMaxAttempts       = "QUEUE_MAX_ATTEMPTS"
+	QueueVisibilityTimeout = "QUEUE_VISIBILITY_TIMEOUT"
+	QueueBackoff           = "QUEUE_BACKOFF"
+	QueuePollInterval      = "QUEUE_POLL_INTERVAL"
+
+	ThumbnailSize = "THUMBNAIL_SIZE"
+
+	ReconcileFix         = "RECONCILE_FIX"
+	ReconcileGracePeriod = "RECONCILE_GRACE_PERIOD"
+	ReconcileInterval    = "RECONCILE_INTERVAL"
+
+	JWTSecret     = "JWT_SECRET"
+	JWTIssuer     = "JWT_ISSUER"
+	JWTAccessTTL  = "JWT_ACCESS_TTL"
+	JWTRefreshTTL = "JWT_REFRESH_TTL"
+
+	OIDCIssuer       = "OIDC_ISSUER"
+	OIDCClientID     = "OIDC_CLIENT_ID"
+	OIDCClientSecret = "OIDC_CLIENT_SECRET"
+	OIDCRedirectURL  = "OIDC_REDIRECT_URL"
+	OIDCScopes       = "OIDC_SCOPES"
+	DevIssuerAddr    = "DEV_ISSUER_ADDR"
+
+	BootstrapUsername = "BOOTSTRAP_USERNAME"
+
+	RateLimits     = "RATE_LIMITS"
+	TrustedProxies = "TRUSTED_PROXIES"
+
+	OpenAIAPIKey        = "OPEN_AI_API_KEY"
+	LLMDriver           = "LLM_DRIVER"
+	LLMModel            = "LLM_MODEL"
+	LLMBaseURL          = "LLM_BASE_URL"
+	LLMAzureAPIVersion  = "LLM_AZURE_API_VERSION"
+	LLMAzureDeployments = "LLM_AZURE_DEPLOYMENTS"
+	LLMScript           = "LLM_SCRIPT"
+	LLMCassetteMode     = "LLM_CASSETTE_MODE"
+	LLMCassetteDir      = "LLM_CASSETTE_DIR"
+	LLMClock            = "LLM_CLOCK"
+	LLMProfiles         = "LLM_PROFILES"
+	LLMPromptCacheTTL   = "LLM_PROMPT_CACHE_TTL"
+	DefaultLocale       = "DEFAULT_LOCALE"
+	LLMTimeout          = "LLM_TIMEOUT"
+	LLMMaxRetries       = "LLM_MAX_RETRIES"
+	LLMRetryBackoff     = "LLM_RETRY_BACKOFF"
+	LLMMaxRetryWait     = "LLM_MAX_RETRY_WAIT"
+	LLMBreakerFailures  = "LLM_BREAKER_FAILURES"
+	LLMBreakerCooldown  = "LLM_BREAKER_COOLDOWN"
+	LLMFallbackDriver   = "LLM_FALLBACK_DRIVER"
+	LLMFallbackModel    = "LLM_FALLBACK_MODEL"
+	LLMFallbackBaseURL  = "LLM_FALLBACK_BASE_URL"
+	LLMFallbackAPIKey   = "LLM_FALLBACK_API_KEY"
+
+	LLMContextTokenBudget = "LLM_CONTEXT_TOKEN_BUDGET"
+	LLMRecentTurns        = "LLM_RECENT_TURNS"
+
+	LLMPrices             = "LLM_PRICES"
+	LLMDailyTokenBudget   = "LLM_DAILY_TOKEN_BUDGET"
+	LLMMonthlyTokenBudget = "LLM_MONTHLY_TOKEN_BUDGET"
+
+	MemoryMaxFacts    = "MEMORY_MAX_FACTS"
+	MemoryPromptFacts = "MEMORY_PROMPT_FACTS"
+
+	PublicURL              = "PUBLIC_URL"
+	ShareLinkDefaultExpiry = "SHARE_LINK_DEFAULT_EXPIRY"
+	ShareLinkMaxExpiry     = "SHARE_LINK_MAX_EXPIRY"
+	SharePresignExpiry     = "SHARE_PRESIGN_EXPIRY"
 )
@@ -3,7 +3,15 @@ package initialize
 import (
 	"context"
 	"fmt"
-	"os"
+	"uber_fx_init_folder_structure/pkg/apikey"
+	"uber_fx_init_folder_structure/pkg/conversation"
+	"uber_fx_init_folder_structure/pkg/experiment"
+	"uber_fx_init_folder_structure/pkg/export"
+	"uber_fx_init_folder_structure/pkg/memory"
+	"uber_fx_init_folder_structure/pkg/prompt"
+	"uber_fx_init_folder_structure/pkg/rbac"
+	"uber_fx_init_folder_structure/pkg/share"
+	"uber_fx_init_folder_structure/pkg/usage"
 	"uber_fx_init_folder_structure/pkg/user"
 
 	"github.com/go-pg/pg/v10"
@@ -50,7 +58,10 @@ func NewDB(conf *viper.Viper, log *logrus.Logger) (out DBOut, err error) {
 	pgPassword := conf.GetString(envPgPassword)
 	pgHost := conf.GetString(envPgHost)
 	pgPort := conf.GetString(envPgPort)
-	db, err := postgresqlInit(pgDB, pgUser, pgPassword, pgHost, pgPort, log)
+	// the server and bootstrap-admin modes migrate, the worker and reconcile modes leave it to the server
+	mode := conf.GetString("mode")
+	migrate := mode != "worker" && mode != "reconcile"
+	db, err := postgresqlInit(pgDB, pgUser, pgPassword, pgHost, pgPort, migrate, log)
 	if err != nil {
 		log.Error(err)
 		return
@@ -61,7 +72,7 @@ func NewDB(conf *viper.Viper, log *logrus.Logger) (out DBOut, err error) {
 	return
 }
 
-func postgresqlInit(dbName, dbUser, dbPassword, dbHost, dbPort string, log *logrus.Logger) (
+func postgresqlInit(dbName, dbUser, dbPassword, dbHost, dbPort string, migrate bool, log *logrus.Logger) (
 	DB *pg.DB, err error) {
 
 	//the DB variable below is a connection pool.
@@ -92,8 +103,12 @@ func postgresqlInit(dbName, dbUser, dbPassword, dbHost, dbPort string, log *logr
 		return
 	}
 
-	if os.Getenv("MODE") == "server" {
-		createSchema(DB)
+	if migrate {
+		if err = createSchema(DB); err != nil {
+			// a partly migrated database fails in confusing ways later, e.g. a missing admin role
+			log.WithField("error", err.Error()).Fatal("postgresql schema migration failed")
+			return
+		}
 	}
 	log.Info("Successfully connected!")
 	log.WithFields(logrus.Fields{
@@ -107,6 +122,20 @@ func createSchema(db *pg.DB) error {
 
 		(*user.User)(nil),
 		(*user.UserImages)(nil),
+		(*export.Job)(nil),
+		(*apikey.APIKey)(nil),
+		(*user.Grant)(nil),
+		(*share.Link)(nil),
+		(*rbac.Role)(nil),
+		(*rbac.UserRole)(nil),
+		(*usage.Record)(nil),
+		(*prompt.Prompt)(nil),
+		(*prompt.ActivePrompt)(nil),
+		(*conversation.Message)(nil),
+		(*conversation.Feedback)(nil),
+		(*conversation.Summary)(nil),
+		(*memory.Memory)(nil),
+		(*experiment.Experiment)(nil),
 	}
 
 	for _, model := range models {
@@ -119,5 +148,62 @@ func createSchema(db *pg.DB) error {
 		}
 	}
 
-	return nil
+	for _, query := range migrations {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return rbac.SeedAdminRole(context.Background(), db, rbac.PermissionNames())
+}
+
+// migrations are run after the tables are created so that columns added to
+// existing models also reach databases created by an older version.
+// Every query must be safe to run on each start.
+var migrations = []string{
+	`ALTER TABLE user_images ADD COLUMN IF NOT EXISTS deleted_at timestamptz`,
+	// photos uploaded before the trash bin existed never had is_active set,
+	// photos deactivated since then are FALSE rather than NULL
+	`UPDATE user_images SET is_active = TRUE WHERE is_active IS NULL AND deleted_at IS NULL`,
+	`ALTER TABLE user_images ADD COLUMN IF NOT EXISTS content_type text`,
+	`ALTER TABLE user_images ADD COLUMN IF NOT EXISTS album text`,
+	`ALTER TABLE user_images ADD COLUMN IF NOT EXISTS tags text[]`,
+	// cursor pagination compares created_at, which older uploads never set
+	`UPDATE user_images SET created_at = COALESCE(updated_at, now()) WHERE created_at IS NULL`,
+	`CREATE INDEX IF NOT EXISTS user_images_user_id_created_at_idx ON user_images (user_id, created_at, id)`,
+	`ALTER TABLE user_images ADD COLUMN IF NOT EXISTS thumbnail_url text`,
+	`ALTER TABLE user_images ADD COLUMN IF NOT EXISTS taken_at timestamptz`,
+	`ALTER TABLE user_images ADD COLUMN IF NOT EXISTS exif jsonb`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash text`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS email text`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS mobile text`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS first_name text`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_name text`,
+	`CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_issuer text`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject text`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS users_oidc_idx ON users (oidc_issuer, oidc_subject)`,
+	// an empty album grants all photos, there is one grant per owner, grantee and album
+	`CREATE UNIQUE INDEX IF NOT EXISTS photo_grants_owner_grantee_album_idx ON photo_grants (owner_id, grantee_id, (COALESCE(album, '')))`,
+	`CREATE INDEX IF NOT EXISTS photo_grants_grantee_id_idx ON photo_grants (grantee_id)`,
+	`CREATE INDEX IF NOT EXISTS share_links_user_id_idx ON share_links (user_id)`,
+	`CREATE INDEX IF NOT EXISTS user_roles_role_id_idx ON user_roles (role_id)`,
+	`CREATE INDEX IF NOT EXISTS llm_usage_user_id_created_at_idx ON llm_usage (user_id, created_at)`,
+	`CREATE INDEX IF NOT EXISTS llm_usage_created_at_idx ON llm_usage (created_at)`,
+	`CREATE INDEX IF NOT EXISTS llm_usage_session_id_idx ON llm_usage (session_id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS prompts_name_version_idx ON prompts (name, version)`,
+	`ALTER TABLE llm_usage ADD COLUMN IF NOT EXISTS experiment_id bigint`,
+	`ALTER TABLE llm_usage ADD COLUMN IF NOT EXISTS variant text`,
+	`CREATE INDEX IF NOT EXISTS llm_usage_experiment_id_idx ON llm_usage (experiment_id) WHERE experiment_id IS NOT NULL`,
+	`CREATE INDEX IF NOT EXISTS chat_messages_user_id_session_id_idx ON chat_messages (user_id, session_id, id)`,
+	`CREATE INDEX IF NOT EXISTS chat_messages_experiment_id_idx ON chat_messages (experiment_id) WHERE experiment_id IS NOT NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS message_feedback_message_id_user_id_idx ON message_feedback (message_id, user_id)`,
+	`CREATE INDEX IF NOT EXISTS message_feedback_created_at_idx ON message_feedback (created_at)`,
+	// only one experiment runs at a time
+	`CREATE UNIQUE INDEX IF NOT EXISTS experiments_running_idx ON experiments ((stopped_at IS NULL)) WHERE stopped_at IS NULL`,
+	`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS tool_calls jsonb`,
+	`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS tool_call_id text`,
+	`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS name text`,
+	// a user remembers a fact once, whatever its case
+	`CREATE UNIQUE INDEX IF NOT EXISTS memories_user_id_content_idx ON memories (user_id, (lower(content)))`,
 }
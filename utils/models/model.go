@@ -1,13 +1,95 @@
 package model
 
+import "time"
+
 type (
 	CreateUserReq struct {
-		Username  string `json:"username"`
-		Password  string `json:"password"`
-		Email     string `json:"email"`
-		Mobile    string `json:"mobile"`
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
+		Username  string `json:"username" binding:"required,alphanum,min=3,max=32"`
+		Password  string `json:"password" binding:"required,min=8,max=72"`
+		Email     string `json:"email" binding:"omitempty,email"`
+		Mobile    string `json:"mobile" binding:"omitempty,e164"`
+		FirstName string `json:"first_name" binding:"max=64"`
+		LastName  string `json:"last_name" binding:"max=64"`
+	}
+	LoginReq struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	APIKeyReq struct {
+		Name   string   `json:"name" binding:"required,max=64"`
+		Scopes []string `json:"scopes" binding:"required,min=1"`
+	}
+	GrantReq struct {
+		Username   string `json:"username" binding:"required"`
+		Album      string `json:"album"`
+		Permission string `json:"permission" binding:"required,oneof=read write"`
+	}
+	ShareLinkReq struct {
+		PhotoID  int    `json:"photo_id"`
+		Album    string `json:"album"`
+		Password string `json:"password"`
+		// Hours is the lifetime of the link, share_link_default_expiry when 0
+		Hours    int `json:"hours" binding:"min=0"`
+		MaxViews int `json:"max_views" binding:"min=0"`
+	}
+	RoleReq struct {
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	PromptReq struct {
+		Messages []string `json:"messages" binding:"required,min=1"`
+		Note     string   `json:"note"`
+	}
+	PromptPreviewReq struct {
+		// Version is previewed when Messages is empty
+		Version  int      `json:"version" binding:"min=0"`
+		Messages []string `json:"messages"`
+		Message  string   `json:"message" binding:"required"`
+	}
+	ActivatePromptReq struct {
+		Version int `json:"version" binding:"required,min=1"`
+	}
+	ExperimentReq struct {
+		Name        string                 `json:"name" binding:"required"`
+		Description string                 `json:"description"`
+		Variants    []ExperimentVariantReq `json:"variants" binding:"required,min=2,dive"`
+	}
+	ExperimentVariantReq struct {
+		Name string `json:"name" binding:"required"`
+		// PromptVersion is the version of the system prompt, the active one when 0
+		PromptVersion int    `json:"prompt_version" binding:"min=0"`
+		Profile       string `json:"profile"`
+		Weight        int    `json:"weight" binding:"min=0"`
+	}
+	FeedbackReq struct {
+		Rating  string   `json:"rating" binding:"required,oneof=up down"`
+		Comment string   `json:"comment" binding:"max=2000"`
+		Reasons []string `json:"reasons" binding:"max=8"`
+	}
+	// WSFrame is a frame of the websocket chat opened with ?protocol=json. Clients send message
+	// and feedback frames, the server sends reply, notification, feedback and error frames.
+	WSFrame struct {
+		Type string `json:"type"`
+		// ID is the ID of the answer on reply frames, to rate it with a feedback frame
+		ID        int      `json:"id,omitempty"`
+		Text      string   `json:"text,omitempty"`
+		MessageID int      `json:"message_id,omitempty"`
+		Rating    string   `json:"rating,omitempty"`
+		Comment   string   `json:"comment,omitempty"`
+		Reasons   []string `json:"reasons,omitempty"`
+	}
+	ChatReq struct {
+		Message string `json:"message" binding:"required"`
+		// SessionID groups the usage of related messages, a new session is started when empty
+		SessionID string `json:"session_id" binding:"max=64"`
+	}
+	UsageReq struct {
+		From time.Time `form:"from" time_format:"2006-01-02"`
+		To   time.Time `form:"to" time_format:"2006-01-02"`
+	}
+	RefreshTokenReq struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
 	}
 	GenericRes struct {
 		Success bool        `json:"success"`
@@ -18,4 +100,24 @@ type (
 	BotReq struct {
 		Input string `json:"input"`
 	}
+	PhotoListReq struct {
+		From        time.Time `form:"from" time_format:"2006-01-02"`
+		To          time.Time `form:"to" time_format:"2006-01-02"`
+		Tag         string    `form:"tag"`
+		Album       string    `form:"album"`
+		ContentType string    `form:"content_type"`
+		Sort        string    `form:"sort"`
+		Cursor      string    `form:"cursor"`
+		Limit       int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	}
+	ExportReq struct {
+		Album string `json:"album"`
+		From  string `json:"from"`
+		To    string `json:"to"`
+	}
+	PageMeta struct {
+		NextCursor string `json:"next_cursor,omitempty"`
+		HasMore    bool   `json:"has_more"`
+		Limit      int    `json:"limit"`
+	}
 )
@@ -1,8 +1,39 @@
 package types
 
+// Permission allows a user to do something beyond their own resources, users get permissions through roles
+type Permission string
+
 const (
-	PRODUCTMANAGEMENT = 1
-	USERMANAGEMENT    = 2
-	REVIEWMANAGEMENT  = 3
-	ORDERMANGEMENT    = 4
+	// USERMANAGEMENT allows managing roles and assigning them to users
+	USERMANAGEMENT Permission = "user_management"
+	// PHOTOMANAGEMENT allows reading and changing every user's photos, trash bins and exports
+	PHOTOMANAGEMENT Permission = "photo_management"
+	// QUEUEMANAGEMENT allows inspecting background jobs and starting maintenance jobs
+	QUEUEMANAGEMENT Permission = "queue_management"
+	// USAGEREPORTS allows reading the chat usage of every user
+	USAGEREPORTS Permission = "usage_reports"
+	// PROMPTMANAGEMENT allows creating, previewing and activating the bot's system prompts
+	PROMPTMANAGEMENT Permission = "prompt_management"
+	// FEEDBACKREVIEW allows exporting the ratings users gave the bot's answers with the conversations they rate
+	FEEDBACKREVIEW Permission = "feedback_review"
 )
+
+// Permissions lists every permission, the admin role has all of them
+var Permissions = []Permission{
+	USERMANAGEMENT,
+	PHOTOMANAGEMENT,
+	QUEUEMANAGEMENT,
+	USAGEREPORTS,
+	PROMPTMANAGEMENT,
+	FEEDBACKREVIEW,
+}
+
+// Valid reports whether p is a known permission
+func (p Permission) Valid() bool {
+	for _, permission := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}